@@ -0,0 +1,391 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/funtoy/protoc-gen-gocmd/gocmd"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// CmdKind is the kind of a command
+type CmdKind string
+
+const (
+	KindRequest  CmdKind = "request"
+	KindResponse CmdKind = "response"
+	KindEvent    CmdKind = "event"
+	KindCustom   CmdKind = "custom"
+)
+
+// cmd_suffix=Request|Response|Event|Notify:event selects the commands by the
+// suffix of the message name, case insensitive, optionally followed by the
+// kind of the command. cmd_regex=^(C2S|S2C) selects them by a regular
+// expression on the message name instead. cmd_from=service ignores the names
+// and takes the commands from the rpcs of the services. The (gocmd.command)
+// option of a message wins over all of them.
+const (
+	optionCmdSuffix = "cmd_suffix"
+	optionCmdRegex  = "cmd_regex"
+	optionCmdFrom   = "cmd_from"
+)
+
+// cmd_label=zh|en|none, or request:[REQ]|response:[RESP]|event:[EVT], sets
+// the labels put before the command names in CmdName, zh by default.
+// cmd_desc appends the leading comment of the message to the names.
+const (
+	optionCmdLabel = "cmd_label"
+	optionCmdDesc  = "cmd_desc"
+)
+
+func init() {
+	RegisterOption(optionCmdSuffix)
+	RegisterOption(optionCmdRegex)
+	RegisterOption(optionCmdFrom)
+	RegisterOption(optionCmdLabel)
+	RegisterOption(optionCmdDesc)
+}
+
+var cmdLabelSets = map[string]map[CmdKind]string{
+	"zh":   {KindRequest: "<<请求>>", KindResponse: "<<响应>>", KindEvent: "<<事件>>"},
+	"en":   {KindRequest: "<<Request>>", KindResponse: "<<Response>>", KindEvent: "<<Event>>"},
+	"none": {},
+}
+
+// cmdSuffix is a message name suffix making a command, the kind is guessed
+// from the name when empty
+type cmdSuffix struct {
+	suffix string
+	kind   CmdKind
+}
+
+var defaultCmdSuffixes = []cmdSuffix{{suffix: "Request"}, {suffix: "Response"}, {suffix: "Event"}}
+
+// cmdRule decides which messages are commands
+type cmdRule struct {
+	suffixes []cmdSuffix
+	regex    *regexp.Regexp
+
+	services bool                              // cmd_from=service
+	rpcKinds map[protoreflect.FullName]CmdKind // kind of the messages used by the rpcs
+	rpcOrder map[string][]*protogen.Message    // messages used by the rpcs of each file, in rpc order
+	rpcPairs map[string][]cmdPair              // request and response of the unary rpcs of each file
+
+	pairs map[string][]cmdPair // cache of cmdPairs by file path
+
+	labels map[CmdKind]string // cmd_label
+}
+
+// cmdPair is a request command and the command of its response
+type cmdPair struct {
+	request  *protogen.Message
+	response *protogen.Message
+	reverse  bool // the request is the one answered by the response, false when the response answers an earlier request too
+}
+
+// loadCmdRule reads the cmd_suffix, cmd_regex, cmd_label and cmd_from params
+func (g *Generator) loadCmdRule() {
+	g.cmdRule.suffixes = defaultCmdSuffixes
+	if value, ok := g.Params[optionCmdSuffix]; ok {
+		g.cmdRule.suffixes = nil
+		for _, s := range strings.Split(value, "|") {
+			if s == "" {
+				continue
+			}
+			suffix := cmdSuffix{suffix: s}
+			if i := strings.Index(s, ":"); i >= 0 {
+				suffix.suffix, suffix.kind = s[:i], CmdKind(strings.ToLower(s[i+1:]))
+				if !suffix.kind.valid() {
+					g.fail("cmd_suffix %s: unknown kind %q, expected request, response, event or custom", s, s[i+1:])
+				}
+			}
+			g.cmdRule.suffixes = append(g.cmdRule.suffixes, suffix)
+		}
+		if len(g.cmdRule.suffixes) == 0 {
+			g.fail("cmd_suffix param requires a list of suffixes, e.g. cmd_suffix=Request|Response|Event|Notify:event")
+		}
+	}
+	if value, ok := g.Params[optionCmdRegex]; ok {
+		regex, err := regexp.Compile(value)
+		if err != nil {
+			g.fail("cmd_regex: %v", err)
+		}
+		g.cmdRule.regex = regex
+	}
+	g.loadCmdLabels()
+	switch from := g.Params[optionCmdFrom]; from {
+	case "", "name":
+	case "service":
+		g.cmdRule.services = true
+		g.loadRpcCommands()
+	default:
+		g.fail("cmd_from: unknown value %q, expected name or service", from)
+	}
+}
+
+// loadCmdLabels reads the cmd_label param
+func (g *Generator) loadCmdLabels() {
+	value, ok := g.Params[optionCmdLabel]
+	if !ok || value == "" {
+		value = "zh"
+	}
+	if labels, ok := cmdLabelSets[value]; ok {
+		g.cmdRule.labels = labels
+		return
+	}
+	g.cmdRule.labels = make(map[CmdKind]string)
+	for _, s := range strings.Split(value, "|") {
+		i := strings.Index(s, ":")
+		if i < 0 || !CmdKind(s[:i]).valid() {
+			g.fail("cmd_label %s: expected zh, en, none or a list of kind:label, e.g. request:[REQ]|response:[RESP]|event:[EVT]", s)
+			continue
+		}
+		g.cmdRule.labels[CmdKind(s[:i])] = s[i+1:]
+	}
+}
+
+// cmdLabel returns the name of the command given in CmdName: the label of its
+// kind, the name and with cmd_desc the leading comment of the message
+func (g *Generator) cmdLabel(msg *protogen.Message) string {
+	name := msg.GoIdent.GoName
+	kind, _ := g.cmdKind(msg)
+	if label := g.cmdRule.labels[kind]; label != "" {
+		name = label + " " + name
+	}
+	if _, ok := g.Params[optionCmdDesc]; ok {
+		if desc := commentLine(msg.Comments.Leading); desc != "" {
+			name += " " + desc
+		}
+	}
+	return name
+}
+
+// loadRpcCommands collects the commands defined by the rpcs of the services:
+// rpc Login(LoginRequest) returns (LoginResponse) gives a request and its
+// response, the streamed messages of rpc Kick(KickRequest) returns (stream
+// KickEvent) are events. google.protobuf.Empty is never a command.
+func (g *Generator) loadRpcCommands() {
+	g.cmdRule.rpcKinds = make(map[protoreflect.FullName]CmdKind)
+	g.cmdRule.rpcOrder = make(map[string][]*protogen.Message)
+	g.cmdRule.rpcPairs = make(map[string][]cmdPair)
+	for _, file := range g.Plugin.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, service := range file.Services {
+			for _, method := range service.Methods {
+				g.addRpcCommand(file, method, method.Input, method.Desc.IsStreamingClient(), KindRequest)
+				g.addRpcCommand(file, method, method.Output, method.Desc.IsStreamingServer(), KindResponse)
+				if !method.Desc.IsStreamingClient() && !method.Desc.IsStreamingServer() {
+					pair := cmdPair{request: method.Input, response: method.Output}
+					g.cmdRule.rpcPairs[file.Desc.Path()] = append(g.cmdRule.rpcPairs[file.Desc.Path()], pair)
+				}
+			}
+		}
+	}
+}
+
+func (g *Generator) addRpcCommand(file *protogen.File, method *protogen.Method, msg *protogen.Message, stream bool, kind CmdKind) {
+	name := msg.Desc.FullName()
+	if name == "google.protobuf.Empty" {
+		return
+	}
+	if stream {
+		kind = KindEvent
+	}
+	if msg.Desc.ParentFile().Path() != file.Desc.Path() {
+		g.fail("%s: rpc %s uses %s, which is not declared in the same file", file.Desc.Path(), method.Desc.FullName(), name)
+		return
+	}
+	if other, ok := g.cmdRule.rpcKinds[name]; ok {
+		if other != kind {
+			g.fail("%s: %s is used as both %s and %s by the rpcs", file.Desc.Path(), name, other, kind)
+		}
+		return
+	}
+	g.cmdRule.rpcKinds[name] = kind
+	g.cmdRule.rpcOrder[file.Desc.Path()] = append(g.cmdRule.rpcOrder[file.Desc.Path()], msg)
+}
+
+// cmdKind tells whether the message is a command, and of which kind
+func (g *Generator) cmdKind(msg *protogen.Message) (CmdKind, bool) {
+	opts := msg.Desc.Options()
+	if proto.HasExtension(opts, gocmd.E_Command) {
+		switch proto.GetExtension(opts, gocmd.E_Command).(gocmd.Kind) {
+		case gocmd.Kind_REQUEST:
+			return KindRequest, true
+		case gocmd.Kind_RESPONSE:
+			return KindResponse, true
+		case gocmd.Kind_EVENT:
+			return KindEvent, true
+		case gocmd.Kind_CUSTOM:
+			return KindCustom, true
+		default:
+			return "", false
+		}
+	}
+
+	if g.cmdRule.services {
+		kind, ok := g.cmdRule.rpcKinds[msg.Desc.FullName()]
+		return kind, ok
+	}
+	name := string(msg.Desc.Name())
+	if g.cmdRule.regex != nil {
+		if !g.cmdRule.regex.MatchString(name) {
+			return "", false
+		}
+		return kindOfName(name), true
+	}
+	for _, suffix := range g.cmdRule.suffixes {
+		if !strings.HasSuffix(strings.ToLower(name), strings.ToLower(suffix.suffix)) {
+			continue
+		}
+		if suffix.kind != "" {
+			return suffix.kind, true
+		}
+		return kindOfName(name), true
+	}
+	return "", false
+}
+
+func (g *Generator) isCmdType(msg *protogen.Message) bool {
+	_, ok := g.cmdKind(msg)
+	return ok
+}
+
+// cmdMessages returns the commands of the file in the order their ids are
+// assigned: by name, or by rpc with cmd_from=service
+func (g *Generator) cmdMessages(file *protogen.File) []*protogen.Message {
+	var msgs []*protogen.Message
+	seen := make(map[protoreflect.FullName]bool)
+	if g.cmdRule.services {
+		for _, msg := range g.cmdRule.rpcOrder[file.Desc.Path()] {
+			if g.isCmdType(msg) {
+				msgs = append(msgs, msg)
+				seen[msg.Desc.FullName()] = true
+			}
+		}
+	}
+	for _, msg := range g.allMessages(file) {
+		if g.isCmdType(msg) && !seen[msg.Desc.FullName()] {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// cmdPairs returns the requests of the file with their response, in the order
+// of cmdMessages. The response is found by name, LoginRequest gives
+// LoginResponse, or with cmd_from=service by the rpc taking the request. A
+// warning is printed for each request without response, and for each response
+// answering several requests, which maps back to the first one only.
+func (g *Generator) cmdPairs(file *protogen.File) []cmdPair {
+	if pairs, ok := g.cmdRule.pairs[file.Desc.Path()]; ok {
+		return pairs
+	}
+
+	responses := make(map[*protogen.Message]*protogen.Message)
+	if g.cmdRule.services {
+		for _, pair := range g.cmdRule.rpcPairs[file.Desc.Path()] {
+			if _, dup := responses[pair.request]; !dup {
+				responses[pair.request] = pair.response
+			}
+		}
+	} else {
+		byName := make(map[string]*protogen.Message)
+		for _, msg := range g.cmdMessages(file) {
+			byName[strings.ToLower(string(msg.Desc.FullName()))] = msg
+		}
+		for _, msg := range g.cmdMessages(file) {
+			if kind, _ := g.cmdKind(msg); kind != KindRequest {
+				continue
+			}
+			base := g.trimCmdSuffix(string(msg.Desc.FullName()), KindRequest)
+			for _, suffix := range g.cmdSuffixesOf(KindResponse) {
+				if resp, ok := byName[strings.ToLower(base+suffix)]; ok {
+					responses[msg] = resp
+					break
+				}
+			}
+		}
+	}
+
+	var pairs []cmdPair
+	answered := make(map[*protogen.Message]*protogen.Message)
+	for _, msg := range g.cmdMessages(file) {
+		if kind, _ := g.cmdKind(msg); kind != KindRequest {
+			continue
+		}
+		resp, ok := responses[msg]
+		if !ok || !g.isCmdType(resp) {
+			g.warn("%s: request %s has no matching response", file.Desc.Path(), msg.Desc.FullName())
+			continue
+		}
+		pair := cmdPair{request: msg, response: resp, reverse: true}
+		if other, dup := answered[resp]; dup {
+			g.warn("%s: response %s answers both %s and %s, it is mapped back to %s only",
+				file.Desc.Path(), resp.Desc.FullName(), other.Desc.FullName(), msg.Desc.FullName(), other.Desc.FullName())
+			pair.reverse = false
+		} else {
+			answered[resp] = msg
+		}
+		pairs = append(pairs, pair)
+	}
+	if g.cmdRule.pairs == nil {
+		g.cmdRule.pairs = make(map[string][]cmdPair)
+	}
+	g.cmdRule.pairs[file.Desc.Path()] = pairs
+	return pairs
+}
+
+// cmdSuffixesOf returns the suffixes giving commands of the kind
+func (g *Generator) cmdSuffixesOf(kind CmdKind) []string {
+	suffixes := g.cmdRule.suffixes
+	if g.cmdRule.regex != nil || g.cmdRule.services {
+		suffixes = defaultCmdSuffixes
+	}
+	var names []string
+	for _, suffix := range suffixes {
+		if suffix.kind == kind || suffix.kind == "" && kindOfName(suffix.suffix) == kind {
+			names = append(names, suffix.suffix)
+		}
+	}
+	return names
+}
+
+// trimCmdSuffix removes the longest suffix of the kind from the name, case
+// insensitive
+func (g *Generator) trimCmdSuffix(name string, kind CmdKind) string {
+	trimmed := name
+	for _, suffix := range g.cmdSuffixesOf(kind) {
+		if strings.HasSuffix(strings.ToLower(name), strings.ToLower(suffix)) && len(name)-len(suffix) < len(trimmed) {
+			trimmed = name[:len(name)-len(suffix)]
+		}
+	}
+	return trimmed
+}
+
+// kindOfName guesses the kind of a command from the suffix of its name
+func kindOfName(name string) CmdKind {
+	s := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(s, "request"):
+		return KindRequest
+	case strings.HasSuffix(s, "response"):
+		return KindResponse
+	case strings.HasSuffix(s, "event"):
+		return KindEvent
+	default:
+		return KindCustom
+	}
+}
+
+func (k CmdKind) valid() bool {
+	switch k {
+	case KindRequest, KindResponse, KindEvent, KindCustom:
+		return true
+	}
+	return false
+}
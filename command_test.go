@@ -0,0 +1,221 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// testMessages returns unpinned test messages of the names
+func testMessages(names ...string) []testMessage {
+	msgs := make([]testMessage, len(names))
+	for i, name := range names {
+		msgs[i].name = name
+	}
+	return msgs
+}
+
+// withService adds to the file a service of unary rpcs, each given as its
+// input and output message names
+func withService(fd *descriptorpb.FileDescriptorProto, rpcs ...[2]string) *descriptorpb.FileDescriptorProto {
+	service := &descriptorpb.ServiceDescriptorProto{Name: proto.String("Game")}
+	for _, rpc := range rpcs {
+		service.Method = append(service.Method, &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(rpc[0] + "To" + rpc[1]),
+			InputType:  proto.String("." + fd.GetPackage() + "." + rpc[0]),
+			OutputType: proto.String("." + fd.GetPackage() + "." + rpc[1]),
+		})
+	}
+	fd.Service = append(fd.Service, service)
+	return fd
+}
+
+func TestLoadCmdRule(t *testing.T) {
+	tests := []struct {
+		params   string
+		suffixes []cmdSuffix
+		regex    string
+		services bool
+		labels   map[CmdKind]string
+		err      string
+	}{{
+		params:   "cmd",
+		suffixes: defaultCmdSuffixes,
+		labels:   cmdLabelSets["zh"],
+	}, {
+		params:   "cmd,cmd_suffix=Req|Ack:response|Notify:EVENT,cmd_label=en",
+		suffixes: []cmdSuffix{{suffix: "Req"}, {suffix: "Ack", kind: KindResponse}, {suffix: "Notify", kind: KindEvent}},
+		labels:   cmdLabelSets["en"],
+	}, {
+		params:   "cmd,cmd_regex=^(C2S|S2C),cmd_label=none",
+		suffixes: defaultCmdSuffixes,
+		regex:    "^(C2S|S2C)",
+		labels:   map[CmdKind]string{},
+	}, {
+		params:   "cmd,cmd_from=service,cmd_label=request:[REQ]|event:[EVT]",
+		suffixes: defaultCmdSuffixes,
+		services: true,
+		labels:   map[CmdKind]string{KindRequest: "[REQ]", KindEvent: "[EVT]"},
+	}, {
+		params: "cmd,cmd_suffix=|",
+		err:    "cmd_suffix param requires a list of suffixes",
+	}, {
+		params: "cmd,cmd_suffix=Req:query",
+		err:    `cmd_suffix Req:query: unknown kind "query"`,
+	}, {
+		params: "cmd,cmd_regex=(",
+		err:    "cmd_regex: error parsing regexp",
+	}, {
+		params: "cmd,cmd_from=rpc",
+		err:    `cmd_from: unknown value "rpc", expected name or service`,
+	}, {
+		params: "cmd,cmd_label=fr",
+		err:    "cmd_label fr: expected zh, en, none or a list of kind:label",
+	}, {
+		params: "cmd,cmd_label=query:[Q]",
+		err:    "cmd_label query:[Q]: expected zh, en, none or a list of kind:label",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.params, func(t *testing.T) {
+			g := NewGenerator()
+			loadTestFiles(t, g, tt.params, testProto("game.proto", "game", "example.com/game"))
+			if tt.err != "" {
+				if !g.Failed() || !strings.Contains(strings.Join(g.errors, "\n"), tt.err) {
+					t.Fatalf("errors %q, want %q", g.errors, tt.err)
+				}
+				return
+			}
+			if g.Failed() {
+				t.Fatalf("unexpected errors %q", g.errors)
+			}
+			rule := g.cmdRule
+			if !reflect.DeepEqual(rule.suffixes, tt.suffixes) {
+				t.Errorf("suffixes %v, want %v", rule.suffixes, tt.suffixes)
+			}
+			regex := ""
+			if rule.regex != nil {
+				regex = rule.regex.String()
+			}
+			if regex != tt.regex {
+				t.Errorf("regex %q, want %q", regex, tt.regex)
+			}
+			if rule.services != tt.services {
+				t.Errorf("services %v, want %v", rule.services, tt.services)
+			}
+			if !reflect.DeepEqual(rule.labels, tt.labels) {
+				t.Errorf("labels %v, want %v", rule.labels, tt.labels)
+			}
+		})
+	}
+}
+
+func TestCmdSuffixes(t *testing.T) {
+	tests := []struct {
+		params  string
+		kind    CmdKind
+		want    []string
+		name    string
+		trimmed string
+	}{{
+		params: "cmd", kind: KindRequest, want: []string{"Request"},
+		name: "game.LoginRequest", trimmed: "game.Login",
+	}, {
+		params: "cmd", kind: KindResponse, want: []string{"Response"},
+		name: "game.loginresponse", trimmed: "game.login",
+	}, {
+		params: "cmd", kind: KindRequest, want: []string{"Request"},
+		name: "game.LoginResponse", trimmed: "game.LoginResponse",
+	}, {
+		params: "cmd,cmd_suffix=Req:request|Request|Ack:response|Notify", kind: KindRequest, want: []string{"Req", "Request"},
+		name: "game.LoginRequest", trimmed: "game.Login",
+	}, {
+		params: "cmd,cmd_suffix=Req:request|Request|Ack:response|Notify", kind: KindResponse, want: []string{"Ack"},
+		name: "game.LoginAck", trimmed: "game.Login",
+	}, {
+		params: "cmd,cmd_suffix=Req:request|Request|Ack:response|Notify", kind: KindCustom, want: []string{"Notify"},
+		name: "game.LoginNotify", trimmed: "game.Login",
+	}, {
+		// the pairing of cmd_regex and cmd_from=service uses the default suffixes
+		params: "cmd,cmd_suffix=Req,cmd_regex=^C2S", kind: KindRequest, want: []string{"Request"},
+		name: "game.C2SLoginRequest", trimmed: "game.C2SLogin",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.params+"/"+string(tt.kind), func(t *testing.T) {
+			g := NewGenerator()
+			loadTestFiles(t, g, tt.params, testProto("game.proto", "game", "example.com/game"))
+			if got := g.cmdSuffixesOf(tt.kind); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cmdSuffixesOf %v, want %v", got, tt.want)
+			}
+			if got := g.trimCmdSuffix(tt.name, tt.kind); got != tt.trimmed {
+				t.Errorf("trimCmdSuffix(%s) %s, want %s", tt.name, got, tt.trimmed)
+			}
+		})
+	}
+}
+
+func TestCmdPairs(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+		fd     *descriptorpb.FileDescriptorProto
+		// want lists the pairs as Request<>Response, or Request>Response when
+		// the response maps back to an earlier request
+		want []string
+	}{{
+		name:   "by name",
+		params: "cmd",
+		fd:     testProto("game.proto", "game", "example.com/game", testMessages("LoginRequest", "LoginResponse", "LogoutRequest", "KickEvent", "Player")...),
+		want:   []string{"LoginRequest<>LoginResponse"},
+	}, {
+		name:   "by name across cases",
+		params: "cmd",
+		fd:     testProto("game.proto", "game", "example.com/game", testMessages("LoginRequest", "Loginresponse")...),
+		want:   []string{"LoginRequest<>Loginresponse"},
+	}, {
+		name:   "custom suffixes",
+		params: "cmd,cmd_suffix=Req:request|Ack:response",
+		fd:     testProto("game.proto", "game", "example.com/game", testMessages("LoginReq", "LoginAck", "LogoutReq")...),
+		want:   []string{"LoginReq<>LoginAck"},
+	}, {
+		name:   "by rpc",
+		params: "cmd,cmd_from=service",
+		fd: withService(testProto("game.proto", "game", "example.com/game", testMessages("Login", "LoginResult", "LoginResponse")...),
+			[2]string{"Login", "LoginResult"}),
+		want: []string{"Login<>LoginResult"},
+	}, {
+		name:   "shared response",
+		params: "cmd,cmd_from=service",
+		fd: withService(testProto("game.proto", "game", "example.com/game", testMessages("Login", "Logout", "Ack")...),
+			[2]string{"Login", "Ack"}, [2]string{"Logout", "Ack"}),
+		want: []string{"Login<>Ack", "Logout>Ack"},
+	}, {
+		name:   "request of several rpcs",
+		params: "cmd,cmd_from=service",
+		fd: withService(testProto("game.proto", "game", "example.com/game", testMessages("Login", "LoginResult", "LoginReply")...),
+			[2]string{"Login", "LoginResult"}, [2]string{"Login", "LoginReply"}),
+		want: []string{"Login<>LoginResult"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator()
+			file := loadTestFiles(t, g, tt.params, tt.fd)[0]
+			if g.Failed() {
+				t.Fatalf("unexpected errors %q", g.errors)
+			}
+			var got []string
+			for _, pair := range g.cmdPairs(file) {
+				arrow := ">"
+				if pair.reverse {
+					arrow = "<>"
+				}
+				got = append(got, string(pair.request.Desc.Name())+arrow+string(pair.response.Desc.Name()))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pairs %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
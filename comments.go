@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// commentLines returns the lines of the leading comment of a proto element
+// followed by those of its trailing comment, detached comments are ignored
+func commentLines(comments protogen.CommentSet) []string {
+	var lines []string
+	for _, c := range []protogen.Comments{comments.Leading, comments.Trailing} {
+		if c == "" {
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(c), "\n"), "\n") {
+			lines = append(lines, strings.TrimRight(strings.TrimPrefix(line, " "), " \t"))
+		}
+	}
+	return lines
+}
+
+// commentLine joins the lines of a proto comment into a single line
+func commentLine(comments protogen.Comments) string {
+	var lines []string
+	for _, line := range strings.Split(string(comments), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// writeGoDoc writes the comment lines as a go doc comment
+func writeGoDoc(buf *bytes.Buffer, indent string, lines []string) {
+	for _, line := range lines {
+		buf.WriteString(indent)
+		buf.WriteString("//")
+		if line != "" {
+			buf.WriteByte(' ')
+			buf.WriteString(line)
+		}
+		buf.WriteByte('\n')
+	}
+}
+
+// writeBlockDoc writes the comment lines as a /** */ doc comment, understood
+// by javadoc, asdoc and the ts tools
+func writeBlockDoc(buf *bytes.Buffer, indent string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	buf.WriteString(indent)
+	buf.WriteString("/**\n")
+	for _, line := range lines {
+		buf.WriteString(indent)
+		buf.WriteString(" *")
+		if line != "" {
+			buf.WriteByte(' ')
+			buf.WriteString(strings.Replace(line, "*/", "*&#47;", -1))
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(indent)
+	buf.WriteString(" */\n")
+}
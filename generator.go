@@ -3,857 +3,541 @@ package main
 import (
 	"bytes"
 	"fmt"
-	googleProto "github.com/golang/protobuf/protoc-gen-go/descriptor"
-	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
-	"log"
+	"go/format"
+	"go/token"
+	"math"
 	"os"
 	"path"
 	"sort"
-	"strconv"
 	"strings"
-)
+	"unicode"
 
-const (
-	targetCmd         string = "cmd"
-	targetPackMsg     string = "pack"
-	targetUnpack      string = "unpack"
-	targetAs          string = "as"
-	targetJava        string = "java"
-	targetTS          string = "ts"
-	targetTSPB        string = "ts.pb"
-	targetTSModel     string = "ts.model"
-	targetGoModelResp string = "go.resp"
+	"github.com/funtoy/protoc-gen-gocmd/gocmd"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
 )
 
 // Generator the auto code generator
 type Generator struct {
-	Request      *plugin.CodeGeneratorRequest
-	Response     *plugin.CodeGeneratorResponse
+	Plugin       *protogen.Plugin
+	Response     *pluginpb.CodeGeneratorResponse
 	Params       map[string]string
-	typesMapping map[string]string
+	typesMapping map[protoreflect.Kind]string
+	lock         *cmdLock
+	cmdIds       map[string]map[protoreflect.FullName]int
+	templates    []string
+	cmdRule      cmdRule
+	runtime      string
+	module       string
+	errors       []string
 }
 
 // NewGenerator create a new code generator
 func NewGenerator() *Generator {
 	g := new(Generator)
-	g.Request = new(plugin.CodeGeneratorRequest)
-	g.Response = new(plugin.CodeGeneratorResponse)
+	g.Response = new(pluginpb.CodeGeneratorResponse)
+	g.Response.SupportedFeatures = proto.Uint64(uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL))
 	g.Params = make(map[string]string)
-	g.typesMapping = make(map[string]string)
-	g.typesMapping["TYPE_DOUBLE"] = "float64"
-	g.typesMapping["TYPE_FLOAT"] = "float32"
-	g.typesMapping["TYPE_INT32"] = "int32"
-	g.typesMapping["TYPE_INT64"] = "int64"
-	g.typesMapping["TYPE_UINT32"] = "uint32"
-	g.typesMapping["TYPE_UINT64"] = "uint64"
-	g.typesMapping["TYPE_SINT32"] = "int32"
-	g.typesMapping["TYPE_SINT64"] = "int64"
-	g.typesMapping["TYPE_FIXED32"] = "uint32"
-	g.typesMapping["TYPE_FIXED64"] = "uint64"
-	g.typesMapping["TYPE_SFIXED32"] = "int32"
-	g.typesMapping["TYPE_SFIXED64"] = "int64"
-	g.typesMapping["TYPE_BOOL"] = "bool"
-	g.typesMapping["TYPE_STRING"] = "string"
-	g.typesMapping["TYPE_BYTES"] = "[]byte"
+	g.cmdIds = make(map[string]map[protoreflect.FullName]int)
+	g.typesMapping = make(map[protoreflect.Kind]string)
+	g.typesMapping[protoreflect.DoubleKind] = "float64"
+	g.typesMapping[protoreflect.FloatKind] = "float32"
+	g.typesMapping[protoreflect.Int32Kind] = "int32"
+	g.typesMapping[protoreflect.Int64Kind] = "int64"
+	g.typesMapping[protoreflect.Uint32Kind] = "uint32"
+	g.typesMapping[protoreflect.Uint64Kind] = "uint64"
+	g.typesMapping[protoreflect.Sint32Kind] = "int32"
+	g.typesMapping[protoreflect.Sint64Kind] = "int64"
+	g.typesMapping[protoreflect.Fixed32Kind] = "uint32"
+	g.typesMapping[protoreflect.Fixed64Kind] = "uint64"
+	g.typesMapping[protoreflect.Sfixed32Kind] = "int32"
+	g.typesMapping[protoreflect.Sfixed64Kind] = "int64"
+	g.typesMapping[protoreflect.BoolKind] = "bool"
+	g.typesMapping[protoreflect.StringKind] = "string"
+	g.typesMapping[protoreflect.BytesKind] = "[]byte"
 	return g
 }
 
-// LoadParams load params from request
-func (g *Generator) LoadParams() {
-	for _, v := range strings.Split(g.Request.GetParameter(), ",") {
-		if i := strings.Index(v, "="); i < 0 {
-			g.Params[v] = "true"
-		} else {
-			g.Params[v[0:i]] = v[i+1:]
-		}
-	}
-}
-
-// GenerateFiles Generate Entrance
-func (g *Generator) GenerateFiles() {
-	flags := make([]bool, 9)
-	_, flags[0] = g.Params[targetAs]
-	_, flags[1] = g.Params[targetCmd]
-	_, flags[2] = g.Params[targetPackMsg]
-	_, flags[3] = g.Params[targetUnpack]
-	_, flags[4] = g.Params[targetJava]
-	_, flags[5] = g.Params[targetTS]
-	_, flags[6] = g.Params[targetTSPB]
-	_, flags[7] = g.Params[targetTSModel]
-	_, flags[8] = g.Params[targetGoModelResp]
-
-	filesToGen := 0
-	for _, v := range flags {
-		if v {
-			filesToGen++
-		}
+// LoadRequest build the protogen plugin from the request. protogen handles the
+// go_package, M and paths params, the others are kept in Params.
+func (g *Generator) LoadRequest(req *pluginpb.CodeGeneratorRequest) error {
+	opts := protogen.Options{
+		ParamFunc: func(name, value string) error {
+			if name == targetTemplate {
+				g.templates = append(g.templates, value)
+			}
+			g.Params[name] = value
+			return nil
+		},
 	}
-
-	if filesToGen == 0 {
-		log.Println("please specify which files to be generated, candidates: cmd,pack,unpack or as")
-		os.Exit(1)
+	plugin, err := opts.New(g.gogoGoPackages(g.legacyGoPackages(req)))
+	if err != nil {
+		return err
 	}
+	g.Plugin = plugin
+	g.module, _ = requestParam(req, "module")
+	return nil
+}
 
-	g.Response.File = make([]*plugin.CodeGeneratorResponse_File, len(g.Request.ProtoFile)*filesToGen)
-	responseFileIndex := 0
-	for _, file := range g.Request.ProtoFile {
-		sort.Sort(ByMsgTypeName(file.MessageType))
-		if flags[1] { // generate cmd file
-			g.Response.File[responseFileIndex] = g.generateCmdFile(file)
-			responseFileIndex++
-		}
-
-		if flags[2] { // generate pack file
-			g.Response.File[responseFileIndex] = g.generatePackFile(file)
-			responseFileIndex++
-		}
-
-		if flags[3] { // generate unpack file
-			g.Response.File[responseFileIndex] = g.generateUnpackFile(file)
-			responseFileIndex++
+// legacyGoPackages give an import path to the files without a usable
+// go_package. protogen requires one for every file, while this plugin has
+// always accepted a bare package name (option go_package = "game") or no
+// go_package at all, in which case the package is named after the proto
+// package. Such files are mapped to the directory of the proto file, so the
+// generated files keep their previous location.
+func (g *Generator) legacyGoPackages(req *pluginpb.CodeGeneratorRequest) *pluginpb.CodeGeneratorRequest {
+	mapped := mappedFiles(req)
+	var params []string
+	for _, file := range req.GetProtoFile() {
+		// go_package is "import/path;name", both parts being optional
+		goPackage := file.GetOptions().GetGoPackage()
+		importPath, packageName := goPackage, ""
+		if i := strings.LastIndex(goPackage, ";"); i >= 0 {
+			importPath, packageName = goPackage[:i], goPackage[i+1:]
+		}
+		if mapped[file.GetName()] || strings.ContainsAny(importPath, "./") {
+			continue
 		}
-
-		if flags[0] { // generate as file
-			g.Response.File[responseFileIndex] = g.generateAsFile(file)
-			responseFileIndex++
+		if packageName == "" {
+			packageName = importPath
 		}
-
-		if flags[4] { // generate java file
-			g.Response.File[responseFileIndex] = g.generateJavaFile(file)
-			responseFileIndex++
+		if packageName == "" {
+			packageName = file.GetPackage()
 		}
-
-		if flags[5] { // generate ts file
-			g.Response.File[responseFileIndex] = g.generateTSFile(file)
-			responseFileIndex++
+		packageName = cleanPackageName(packageName)
+		if packageName == "" {
+			g.fail("%s: cannot determine the go package, set option go_package or declare a package", file.GetName())
+			continue
 		}
+		// the package name is part of the import path so that files of the same
+		// directory may still declare different packages, the trailing ".."
+		// cancels it out of the generated file names
+		importPath = "./" + path.Join(path.Dir(file.GetName()), packageName) + legacyImportSuffix
+		params = append(params, "M"+file.GetName()+"="+importPath+";"+packageName)
+	}
+	return withParams(req, params)
+}
 
-		if flags[6] { // generate ts proto builder file
-			g.Response.File[responseFileIndex] = g.generateTSProtoBuilderFile(file)
-			responseFileIndex++
+// requestParam returns the value of a param of the request, before protogen
+// parses them
+func requestParam(req *pluginpb.CodeGeneratorRequest, name string) (string, bool) {
+	for _, param := range strings.Split(req.GetParameter(), ",") {
+		if param == name {
+			return "", true
 		}
-
-		if flags[7] { // generate ts proto model file
-			g.Response.File[responseFileIndex] = g.generateTSProtoModelFile(file)
-			responseFileIndex++
+		if strings.HasPrefix(param, name+"=") {
+			return param[len(name)+1:], true
 		}
+	}
+	return "", false
+}
 
-		if flags[8] { // generate ts proto model file
-			g.Response.File[responseFileIndex] = g.generateGoRespModelFile(file)
-			responseFileIndex++
+// mappedFiles returns the proto files given an import path by an M param
+func mappedFiles(req *pluginpb.CodeGeneratorRequest) map[string]bool {
+	mapped := make(map[string]bool)
+	for _, param := range strings.Split(req.GetParameter(), ",") {
+		if strings.HasPrefix(param, "M") {
+			if i := strings.Index(param, "="); i > 0 {
+				mapped[param[1:i]] = true
+			}
 		}
 	}
+	return mapped
 }
 
-func (g *Generator) getTsTypesMapping(name string) string {
-	switch name {
-	case "TYPE_DOUBLE", "TYPE_FLOAT", "TYPE_INT32", "TYPE_INT64", "TYPE_UINT32", "TYPE_UINT64", "TYPE_SINT32",
-		"TYPE_SINT64", "TYPE_FIXED32", "TYPE_FIXED64", "TYPE_SFIXED32", "TYPE_SFIXED64":
-		return "number"
-
-	case "TYPE_BOOL":
-		return "bool"
-
-	case "TYPE_STRING":
-		return "string"
-
-	case "TYPE_BYTES":
-		return "byte"
-
-	default:
-		return ""
-
+// withParams returns a copy of the request with the params put before the
+// params of the user, which therefore win
+func withParams(req *pluginpb.CodeGeneratorRequest, params []string) *pluginpb.CodeGeneratorRequest {
+	if len(params) == 0 {
+		return req
+	}
+	req = proto.Clone(req).(*pluginpb.CodeGeneratorRequest)
+	if req.GetParameter() != "" {
+		params = append(params, req.GetParameter())
 	}
+	req.Parameter = proto.String(strings.Join(params, ","))
+	return req
 }
 
-func (g *Generator) isProto3(file *googleProto.FileDescriptorProto) bool {
-	return file.GetSyntax() == "proto3"
-}
+// legacyImportSuffix ends the import paths made up by legacyGoPackages
+const legacyImportSuffix = "/.."
 
-func (g *Generator) filename(file *googleProto.FileDescriptorProto) string {
-	fileSuffix := path.Ext(*file.Name)
-	return (*file.Name)[0:len(*file.Name)-len(fileSuffix)]
+// isLegacyImportPath tells whether the import path was made up by
+// legacyGoPackages, such a path cannot be imported
+func isLegacyImportPath(importPath protogen.GoImportPath) bool {
+	return strings.HasPrefix(string(importPath), "./") && strings.HasSuffix(string(importPath), legacyImportSuffix)
 }
 
-func (g *Generator) getAppId(file *googleProto.FileDescriptorProto) int {
-	for _, v := range file.GetEnumType() {
-		if v.GetName() == "App" {
-			for _, x := range v.GetValue() {
-				if x.GetName() == "Id" {
-					return int(x.GetNumber())
-				}
-			}
+// cleanPackageName turns the name into a valid go package name, the same way
+// protoc-gen-go does: game.v1 gives game_v1
+func cleanPackageName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
 		}
+		return '_'
+	}, name)
+	if name != "" && unicode.IsDigit([]rune(name)[0]) {
+		name = "_" + name
 	}
-	return 1
-}
-
-func (g *Generator) isEnumType(name string, file *googleProto.FileDescriptorProto) bool {
-	for _, v := range file.GetEnumType() {
-		if v.GetName() == name {
-			return true
-		}
+	if token.IsKeyword(name) {
+		name = "_" + name
 	}
-	return false
+	return name
 }
 
-func (g *Generator) isCmdType(name string) bool {
-	s := strings.ToLower(name)
-	return strings.HasSuffix(s, "request") ||
-		strings.HasSuffix(s, "response") ||
-		strings.HasSuffix(s, "event")
+// LoadLock load the command id lock file given by the lock param, if any
+func (g *Generator) LoadLock() error {
+	lockPath, ok := g.Params["lock"]
+	if !ok {
+		return nil
+	}
+	if lockPath == "" {
+		lockPath = defaultLockFile
+	}
+	lock, err := loadCmdLock(lockPath)
+	if err != nil {
+		return err
+	}
+	g.lock = lock
+	return nil
 }
 
-func (g *Generator) generateGoFileHeader(buf *bytes.Buffer, file *googleProto.FileDescriptorProto) {
-	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
-	buf.WriteString("// source: ")
-	buf.WriteString(*file.Name)
-	buf.WriteByte('\n')
-	buf.WriteString("// DO NOT EDIT!\n")
-	buf.WriteByte('\n')
-	buf.WriteString("package ")
-	var packageName string
-	if *file.Options.GoPackage != "" {
-		packageName = *file.Options.GoPackage
-	} else {
-		packageName = strings.Replace(*file.Package, ".", "_", -1)
+// SaveLock write back the command ids assigned during generation, nothing is
+// written when the generation failed
+func (g *Generator) SaveLock() error {
+	if g.lock == nil || g.Failed() {
+		return nil
 	}
-	buf.WriteString(packageName)
-	buf.WriteByte('\n')
-	buf.WriteByte('\n')
+	return g.lock.save()
 }
 
-func (g *Generator) generateUnpackFile(file *googleProto.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
-	tab := "    " // 4 spaces for tab by default
-	if _, ok := g.Params["usetabs"]; ok {
-		tab = "\t"
+// GenerateFiles Generate Entrance
+func (g *Generator) GenerateFiles() {
+	enabled := g.enabledTargets()
+	g.loadCmdRule()
+	g.loadRuntime()
+	if g.Failed() {
+		return
 	}
 
-	buf := new(bytes.Buffer)
-	g.generateGoFileHeader(buf, file)
-	buf.WriteString("import \"fmt\"\n")
-	buf.WriteByte('\n')
-	buf.WriteString("func Unpack(fromCmd int32, data []byte) (interface{}, error) {\n")
-	buf.WriteString(tab)
-	buf.WriteString("switch fromCmd {\n")
-	for _, msg := range file.GetMessageType() {
-		typeName := strings.Title(msg.GetName())
-		if !g.isCmdType(msg.GetName()) {
+	packageFiles := make(map[protoreflect.FullName]*protogen.File)
+	for _, file := range g.Plugin.Files {
+		if !file.Generate {
 			continue
 		}
-
-		buf.WriteString(tab)
-		buf.WriteString("case Cmd_")
-		buf.WriteString(typeName)
-		buf.WriteString(":\n")
-		buf.WriteString(tab)
-		buf.WriteString(tab)
-		buf.WriteString("pb := new(")
-		buf.WriteString(typeName)
-		buf.WriteString(")\n")
-		buf.WriteString(tab)
-		buf.WriteString(tab)
-		buf.WriteString("err := pb.Unmarshal(data)\n")
-		buf.WriteString(tab)
-		buf.WriteString(tab)
-		buf.WriteString("return pb, err\n\n")
-	}
-
-	buf.WriteString(tab)
-	buf.WriteString("default:\n")
-	buf.WriteString(tab)
-	buf.WriteString(tab)
-	buf.WriteString("return nil, fmt.Errorf(\"unHandle cmd:%x\", fromCmd)\n")
-	buf.WriteString(tab)
-	buf.WriteString("}\n}")
-
-	response := new(plugin.CodeGeneratorResponse_File)
-	generatedFileName := g.filename(file) + ".unpack.go"
-	fileContent := buf.String()
-	response.Name = &generatedFileName
-	response.Content = &fileContent
-	return response
-}
-
-func (g *Generator) generatePackFile(file *googleProto.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
-	tab := "    " // 4 spaces for tab by default
-	if _, ok := g.Params["usetabs"]; ok {
-		tab = "\t"
-	}
-
-	buf := new(bytes.Buffer)
-	isFirstMsg := true
-	g.generateGoFileHeader(buf, file)
-
-	for _, msg := range file.GetMessageType() {
-		if !isFirstMsg {
-			buf.WriteByte('\n')
+		sort.Sort(ByMsgTypeName(file.Messages))
+		first, seen := packageFiles[file.Desc.Package()]
+		if !seen {
+			packageFiles[file.Desc.Package()] = file
 		}
-
-		isFirstArgument := true
-		assignmentBuf := new(bytes.Buffer)
-		msgTypeName := strings.Title(msg.GetName())
-
-		buf.WriteString("func New")
-
-		buf.WriteString(msgTypeName)
-		buf.WriteByte('(')
-		for _, field := range msg.GetField() {
-			if !isFirstArgument {
-				buf.WriteString(", ")
-			}
-
-			argumentName := strings.ToUpper(field.GetName()) //strings.Title(field.GetName())
-			attributeName := strings.Title(field.GetName())
-			buf.WriteString(argumentName)
-			buf.WriteByte(' ')
-			typeName, builtinType := g.typesMapping[field.GetType().String()]
-			repeatedField := field.GetLabel().String() == "LABEL_REPEATED"
-			var isEnumType bool
-			if !builtinType {
-				typeName = strings.Title(field.GetTypeName()[strings.LastIndex(field.GetTypeName(), ".")+1:])
-				isEnumType = g.isEnumType(typeName, file)
-				if !isEnumType {
-					typeName = "*" + typeName
-				}
-			}
-
-			if repeatedField {
-				typeName = "[]" + typeName
+		for _, t := range enabled {
+			if t.PerPackage() && seen {
+				g.fail("%s: target %s writes one file per package, but %s is already generated for package %s",
+					file.Desc.Path(), t.Name(), first.Desc.Path(), file.Desc.Package())
+				continue
 			}
-
-			buf.WriteString(typeName)
-
-			assignmentBuf.WriteString(tab)
-			assignmentBuf.WriteString(tab)
-			assignmentBuf.WriteString(attributeName)
-			assignmentBuf.WriteString(": ")
-			if builtinType && !strings.HasPrefix(typeName, "[]") {
-				if g.isProto3(file) {
-					assignmentBuf.WriteString(argumentName)
-					assignmentBuf.WriteString(",\n")
-
-				} else {
-					assignmentBuf.WriteByte('&')
-					assignmentBuf.WriteString(argumentName)
-					assignmentBuf.WriteString(",\n")
-				}
-			} else {
-				if isEnumType && !g.isProto3(file) {
-					assignmentBuf.WriteString("&")
+			for _, f := range t.Generate(g, file) {
+				if strings.HasSuffix(f.GetName(), ".go") {
+					g.formatGo(f)
 				}
-				assignmentBuf.WriteString(argumentName)
-				assignmentBuf.WriteString(",\n")
+				g.Response.File = append(g.Response.File, f)
 			}
-
-			if isFirstArgument {
-				isFirstArgument = false
-			}
-		}
-		buf.WriteString(") *")
-		buf.WriteString(msgTypeName)
-		buf.WriteString(" {\n")
-
-		buf.WriteString(tab)
-		buf.WriteString("return &")
-		buf.WriteString(msgTypeName)
-		if assignmentBuf.Len() > 0 {
-			buf.WriteString("{\n")
-			buf.WriteString(assignmentBuf.String())
-			buf.WriteString(tab)
-			buf.WriteString("}\n")
-		} else {
-			buf.WriteString("{}\n")
-		}
-		buf.WriteString("}\n")
-
-		// generate marshal code
-		buf.WriteString("func (m *")
-		buf.WriteString(msgTypeName)
-		buf.WriteString(") Bytes() []byte {\n")
-		buf.WriteString(tab)
-		buf.WriteString("data, err := m.Marshal()\n")
-		buf.WriteString(tab)
-		buf.WriteString("if err != nil { panic(err) }\n")
-		buf.WriteString(tab)
-		buf.WriteString("return data\n")
-		buf.WriteString("}\n")
-
-		if isFirstMsg {
-			isFirstMsg = false
 		}
 	}
-
-	response := new(plugin.CodeGeneratorResponse_File)
-	generatedFileName := g.filename(file) + ".pack.go"
-	fileContent := buf.String()
-	response.Name = &generatedFileName
-	response.Content = &fileContent
-	return response
 }
 
-func (g *Generator) generateGoRespModelFile(file *googleProto.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
-	buf := new(bytes.Buffer)
-	g.generateGoFileHeader(buf, file)
-	buf.WriteString("import \"sync\"\n\n")
-	if !g.isProto3(file) {
-		buf.WriteString("import \"github.com/gogo/protobuf/proto\"\n\n")
+// formatGo run the generated go file through gofmt, the source is included in
+// the error so that the broken output can be seen from protoc
+func (g *Generator) formatGo(f *pluginpb.CodeGeneratorResponse_File) {
+	formatted, err := format.Source([]byte(f.GetContent()))
+	if err != nil {
+		src := new(bytes.Buffer)
+		for i, line := range strings.Split(strings.TrimSuffix(f.GetContent(), "\n"), "\n") {
+			fmt.Fprintf(src, "%5d\t%s\n", i+1, line)
+		}
+		g.fail("%s: cannot format generated go code: %v\n%s", f.GetName(), err, src)
+		return
 	}
-	buf.WriteString("var msgPool = &sync.Pool{New: func() interface{} { return new(ResponseMessage) }}\n\n")
-
-	var FuncStr = func(MsgTypeName string, withCode, withBody bool) string {
-		okBuf := new(bytes.Buffer)
-		okBuf.WriteString("\tresp := msgPool.Get().(*ResponseMessage)\n")
-		if g.isProto3(file) {
-			okBuf.WriteString("\tresp.MessageType = Cmd_" + MsgTypeName)
+	content := string(formatted)
+	f.Content = &content
+}
 
-		} else {
-			okBuf.WriteString("\tresp.MessageType = proto.Int32(Cmd_" + MsgTypeName + ")")
-		}
+// fail record a generation error, all errors are reported to protoc together
+// through the response once the generation is done
+func (g *Generator) fail(format string, args ...interface{}) {
+	g.errors = append(g.errors, fmt.Sprintf(format, args...))
+}
 
-		okBuf.WriteByte('\n')
-		okBuf.WriteString("\tresp.ErrorCode = ")
-		if withCode {
-			okBuf.WriteString("CODE_SUCCESS")
-		} else {
-			okBuf.WriteString("errCode")
-		}
-		if !g.isProto3(file) {
-			okBuf.WriteString(".Enum()")
-		}
+// warn print a warning on the standard error, which protoc shows to the user
+// without failing the generation
+func (g *Generator) warn(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "protoc-gen-gocmd: warning: "+format+"\n", args...)
+}
 
-		okBuf.WriteByte('\n')
-		okBuf.WriteString("\tresp.Body = ")
-		if withBody {
-			okBuf.WriteString("msg.Bytes()")
-		} else {
-			okBuf.WriteString("nil")
-		}
-		okBuf.WriteByte('\n')
-		okBuf.WriteString("\tret := resp.Bytes()")
-		okBuf.WriteByte('\n')
-		okBuf.WriteString("\tmsgPool.Put(resp)")
-		okBuf.WriteByte('\n')
-		okBuf.WriteString("\treturn ret")
-		okBuf.WriteByte('\n')
-		return okBuf.String()
-	}
-
-	for _, msg := range file.GetMessageType() {
-
-		msgTypeName := strings.Title(msg.GetName())
-		if strings.HasSuffix(msgTypeName, "Request") ||
-			strings.HasSuffix(msgTypeName, "Response") ||
-			strings.HasSuffix(msgTypeName, "Event") {
-
-			//error message
-			buf.WriteString("func Reply")
-			buf.WriteString(msgTypeName)
-			buf.WriteString("Err(errCode CODE) []byte {\n")
-			buf.WriteString(FuncStr(msgTypeName, false, false))
-			buf.WriteByte('}')
-			buf.WriteByte('\n')
-			buf.WriteByte('\n')
-
-			//ok message but empty
-			buf.WriteString("func Reply")
-			buf.WriteString(msgTypeName)
-			buf.WriteString("Ok() []byte {\n")
-			buf.WriteString(FuncStr(msgTypeName, true, false))
-			buf.WriteByte('}')
-			buf.WriteByte('\n')
-			buf.WriteByte('\n')
-
-			//ok message with body
-			buf.WriteString("func Reply")
-			buf.WriteString(msgTypeName)
-			buf.WriteString("OkWith(msg *")
-			buf.WriteString(msgTypeName)
-			buf.WriteString(") []byte {\n")
-			buf.WriteString(FuncStr(msgTypeName, true, true))
-			buf.WriteByte('}')
-			buf.WriteByte('\n')
-			buf.WriteByte('\n')
-		}
+// Failed tells whether any error was recorded
+func (g *Generator) Failed() bool {
+	return len(g.errors) > 0
+}
 
+// ReportErrors move the recorded errors into the response, protoc ignores the
+// generated files when the response carries an error
+func (g *Generator) ReportErrors() {
+	if !g.Failed() {
+		return
 	}
-
-	response := new(plugin.CodeGeneratorResponse_File)
-	generatedFileName := g.filename(file) + ".resp.go"
-	fileContent := buf.String()
-	response.Name = &generatedFileName
-	response.Content = &fileContent
-	return response
+	msg := strings.Join(g.errors, "\n")
+	g.Response.Error = &msg
+	g.Response.File = nil
 }
 
-func (g *Generator) generateCmdFile(file *googleProto.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
-	buf := new(bytes.Buffer)
-	g.generateGoFileHeader(buf, file)
-	messageCount := len(file.MessageType)
-	messageIDOffset := 0x1000 * g.getAppId(file)
-	for messageIDOffset < messageCount {
-		messageIDOffset <<= 4
-	}
+func (g *Generator) getTsTypesMapping(kind protoreflect.Kind) string {
+	switch kind {
+	case protoreflect.DoubleKind, protoreflect.FloatKind, protoreflect.Int32Kind, protoreflect.Int64Kind,
+		protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.Sint32Kind, protoreflect.Sint64Kind,
+		protoreflect.Fixed32Kind, protoreflect.Fixed64Kind, protoreflect.Sfixed32Kind, protoreflect.Sfixed64Kind:
+		return "number"
 
-	messageID := messageIDOffset + 1
-	for _, v := range file.GetMessageType() {
-		if !g.isCmdType(v.GetName()) {
-			continue
-		}
-		buf.WriteString("const Cmd_")
-		buf.WriteString(strings.Title(v.GetName()))
-		buf.WriteString(fmt.Sprintf(" = 0x%X\n", messageID))
-		messageID++
-	}
+	case protoreflect.BoolKind:
+		return "bool"
 
-	buf.WriteByte('\n')
-	buf.WriteString("var CmdName = map[int32]string{\n")
+	case protoreflect.StringKind:
+		return "string"
 
-	for _, v := range file.GetMessageType() {
-		if !g.isCmdType(v.GetName()) {
-			continue
-		}
-		name := strings.Title(v.GetName())
-		var protoType string
-		if strings.HasSuffix(name, "Event") {
-			protoType = "<<事件>> "
-		}
-		if strings.HasSuffix(name, "Response") {
-			protoType = "<<响应>> "
-		}
-		if strings.HasSuffix(name, "Request") {
-			protoType = "<<请求>> "
-		}
+	case protoreflect.BytesKind:
+		return "byte"
+
+	default:
+		return ""
 
-		buf.WriteString("\tCmd_")
-		buf.WriteString(name)
-		buf.WriteString(": \"")
-		buf.WriteString(protoType)
-		buf.WriteString(name)
-		buf.WriteString("\",\n")
 	}
-	buf.WriteString("}\n")
+}
 
-	response := new(plugin.CodeGeneratorResponse_File)
-	generatedFileName := g.filename(file) + ".cmd.go"
-	fileContent := buf.String()
-	response.Name = &generatedFileName
-	response.Content = &fileContent
-	return response
+func (g *Generator) isProto3(file *protogen.File) bool {
+	return file.Desc.Syntax() == protoreflect.Proto3
 }
 
-func (g *Generator) generateAsFile(file *googleProto.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
-	buf := new(bytes.Buffer)
-	ns, hasNs := g.Params["asns"]
-	if !hasNs {
-		ns = file.GetPackage()
+// filename returns the path prefix of the generated go files, without extension
+// and relative to the module param if any
+func (g *Generator) filename(file *protogen.File) string {
+	if g.module == "" {
+		return file.GeneratedFilenamePrefix
 	}
-
-	tab := "    " // 4 spaces for tab by default
-	if _, ok := g.Params["usetabs"]; ok {
-		tab = "\t"
+	// protogen only trims the module param in Plugin.Response, which is not
+	// used here
+	prefix := g.module + "/"
+	if !strings.HasPrefix(file.GeneratedFilenamePrefix, prefix) {
+		g.fail("%s: generated file %s does not match prefix %q", file.Desc.Path(), file.GeneratedFilenamePrefix, g.module)
+		return file.GeneratedFilenamePrefix
 	}
+	return strings.TrimPrefix(file.GeneratedFilenamePrefix, prefix)
+}
 
-	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
-	buf.WriteString("// source: ")
-	buf.WriteString(*file.Name)
-	buf.WriteByte('\n')
-	buf.WriteString("// DO NOT EDIT!\n")
-	buf.WriteByte('\n')
-	buf.WriteString("package ")
-	buf.WriteString(ns)
-	buf.WriteString("\n{\n")
-	buf.WriteString(tab)
-	buf.WriteString("public class ProtocolType{\n")
-	messageCount := len(file.MessageType)
-	messageIDOffset := 0x1000 * g.getAppId(file)
-	for messageIDOffset < messageCount {
-		messageIDOffset <<= 4
-	}
+// baseName returns the name of the proto file without directory and extension
+func (g *Generator) baseName(file *protogen.File) string {
+	name := path.Base(file.Desc.Path())
+	return name[0 : len(name)-len(path.Ext(name))]
+}
 
-	messageID := messageIDOffset + 1
-	for _, msg := range file.GetMessageType() {
-		if !g.isCmdType(msg.GetName()) {
-			continue
+// allMessages returns the messages of the file, the top level ones in name
+// order followed by the nested ones in full name order. Nested messages come
+// last so that they never shift the ids of the top level commands. The
+// synthetic map entry messages are left out.
+func (g *Generator) allMessages(file *protogen.File) []*protogen.Message {
+	var nested []*protogen.Message
+	var walk func(msgs []*protogen.Message)
+	walk = func(msgs []*protogen.Message) {
+		for _, msg := range msgs {
+			if msg.Desc.IsMapEntry() {
+				continue
+			}
+			nested = append(nested, msg)
+			walk(msg.Messages)
 		}
-		buf.WriteString(tab)
-		buf.WriteString(tab)
-		buf.WriteString("public static const ")
-		buf.WriteString(strings.Title(msg.GetName()))
-		buf.WriteString(fmt.Sprintf(" : int = 0x%X;\n", messageID))
-		messageID++
 	}
-
-	buf.WriteString(tab)
-	buf.WriteString("}\n}\n")
-	response := new(plugin.CodeGeneratorResponse_File)
-	generatedFileName := "ProtocolType.as"
-	fileContent := buf.String()
-	response.Name = &generatedFileName
-	response.Content = &fileContent
-	return response
-}
-
-func (g *Generator) generateTSFile(file *googleProto.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
-	buf := new(bytes.Buffer)
-
-	tab := "    " // 4 spaces for tab by default
-	if _, ok := g.Params["usetabs"]; ok {
-		tab = "\t"
+	for _, msg := range file.Messages {
+		walk(msg.Messages)
 	}
+	sort.Slice(nested, func(i, j int) bool {
+		return nested[i].Desc.FullName() < nested[j].Desc.FullName()
+	})
 
-	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
-	buf.WriteString("// source: ")
-	buf.WriteString(*file.Name)
-	buf.WriteByte('\n')
-	buf.WriteString("// DO NOT EDIT!\n")
-	buf.WriteByte('\n')
-	buf.WriteString("module proto.cmd {\n")
-	messageCount := len(file.MessageType)
-	messageIDOffset := 0x1000 * g.getAppId(file)
-	for messageIDOffset < messageCount {
-		messageIDOffset <<= 4
-	}
+	msgs := make([]*protogen.Message, 0, len(file.Messages)+len(nested))
+	msgs = append(msgs, file.Messages...)
+	return append(msgs, nested...)
+}
 
-	messageID := messageIDOffset + 1
-	for _, msg := range file.GetMessageType() {
-		if !g.isCmdType(msg.GetName()) {
-			continue
+// allEnums returns the enums of the file, including the ones nested in messages
+func (g *Generator) allEnums(file *protogen.File) []*protogen.Enum {
+	enums := append([]*protogen.Enum(nil), file.Enums...)
+	var walk func(msgs []*protogen.Message)
+	walk = func(msgs []*protogen.Message) {
+		for _, msg := range msgs {
+			enums = append(enums, msg.Enums...)
+			walk(msg.Messages)
 		}
-		buf.WriteString(tab)
-		buf.WriteString("export var ")
-		buf.WriteString(strings.Title(msg.GetName()))
-		buf.WriteString(fmt.Sprintf(": number = 0x%X;\n", messageID))
-		messageID++
 	}
-
-	buf.WriteString("}\n")
-	response := new(plugin.CodeGeneratorResponse_File)
-	generatedFileName := "proto.cmd.ts"
-	fileContent := buf.String()
-	response.Name = &generatedFileName
-	response.Content = &fileContent
-	return response
+	walk(file.Messages)
+	return enums
 }
 
-func (g *Generator) generateTSProtoBuilderFile(file *googleProto.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
-	buf := new(bytes.Buffer)
-
-	tab := "    " // 4 spaces for tab by default
-	if _, ok := g.Params["usetabs"]; ok {
-		tab = "\t"
+// localName returns the name of a type relative to its proto package, e.g.
+// Outer.Inner for a message Inner nested in Outer
+func localName(desc protoreflect.Descriptor) string {
+	name := string(desc.FullName())
+	if pkg := desc.ParentFile().Package(); pkg != "" {
+		name = strings.TrimPrefix(name, string(pkg)+".")
 	}
+	return name
+}
 
-	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
-	buf.WriteString("// source: ")
-	buf.WriteString(*file.Name)
-	buf.WriteByte('\n')
-	buf.WriteString("// DO NOT EDIT!\n")
-	buf.WriteByte('\n')
-	buf.WriteString("module proto {\n")
-
-	filename := g.filename(file)
+// flatName returns the local name of a type usable as an identifier, e.g.
+// Outer_Inner, the same naming as protoc-gen-go
+func flatName(desc protoreflect.Descriptor) string {
+	return strings.Replace(localName(desc), ".", "_", -1)
+}
 
-	for _, msg := range file.GetMessageType() {
-		if !g.isCmdType(msg.GetName()) {
-			continue
-		}
-		buf.WriteString(tab)
-		buf.WriteString("export var ")
-		buf.WriteString(strings.ToUpper(filename))
-		buf.WriteString("_")
-		buf.WriteString(msg.GetName())
-		buf.WriteString(" = { cmd: proto.")
-		buf.WriteString(filename)
-		buf.WriteString(".")
-		buf.WriteString(msg.GetName())
-		buf.WriteString(", cls: \"proto.builder.")
-		buf.WriteString(msg.GetName())
-		buf.WriteString("\"")
-		if msg.GetName() == "LoginResponse" {
-			buf.WriteString(", auto_listen: false")
+func (g *Generator) getAppId(file *protogen.File) int {
+	for _, v := range file.Enums {
+		if v.Desc.Name() == "App" {
+			for _, x := range v.Values {
+				if x.Desc.Name() == "Id" {
+					return int(x.Desc.Number())
+				}
+			}
 		}
-		buf.WriteString("};\n")
 	}
+	return 1
+}
 
-	buf.WriteString("}\n")
-	response := new(plugin.CodeGeneratorResponse_File)
-	generatedFileName := "proto.builder.ts"
-	fileContent := buf.String()
-	response.Name = &generatedFileName
-	response.Content = &fileContent
-	return response
+// getPinnedId returns the id declared by the (gocmd.id) option of the message
+func (g *Generator) getPinnedId(msg *protogen.Message) (int, bool) {
+	opts := msg.Desc.Options()
+	if !proto.HasExtension(opts, gocmd.E_Id) {
+		return 0, false
+	}
+	return int(proto.GetExtension(opts, gocmd.E_Id).(int32)), true
 }
 
-func (g *Generator) generateTSProtoModelFile(file *googleProto.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
-	buf := new(bytes.Buffer)
+// validCmdId tells whether the id can be used as a command id, the cmd being
+// an int32 and 0 meaning no command
+func validCmdId(id int) bool {
+	return id > 0 && id <= math.MaxInt32
+}
 
-	tab := "    " // 4 spaces for tab by default
-	if _, ok := g.Params["usetabs"]; ok {
-		tab = "\t"
+// getCmdIds assign an id to every command message of the file. Messages with a
+// (gocmd.id) option keep the pinned id, then the ids recorded in the lock file
+// are reused, the others are numbered by name order from the app offset,
+// skipping the ids already taken or reserved by the lock.
+func (g *Generator) getCmdIds(file *protogen.File) map[protoreflect.FullName]int {
+	if ids, ok := g.cmdIds[file.Desc.Path()]; ok {
+		return ids
 	}
 
-	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
-	buf.WriteString("// source: ")
-	buf.WriteString(*file.Name)
-	buf.WriteByte('\n')
-	buf.WriteString("// DO NOT EDIT!\n")
-	buf.WriteByte('\n')
-	buf.WriteString("module proto.model {\n")
-
-	for _, enumType := range file.GetEnumType() {
-		buf.WriteString(tab)
-		buf.WriteString("export enum ")
-		buf.WriteString(enumType.GetName())
-		buf.WriteString(" {\n")
-		for _, enumElement := range enumType.GetValue() {
-			buf.WriteString(tab)
-			buf.WriteString(tab)
-			buf.WriteString(enumElement.GetName())
-			buf.WriteString(" = ")
-			buf.WriteString(strconv.Itoa(int(enumElement.GetNumber())))
-			buf.WriteString(",")
-			buf.WriteString("\n")
+	msgs := g.allMessages(file)
+	ids := make(map[protoreflect.FullName]int)
+	used := make(map[int]protoreflect.FullName)
+	if g.lock != nil {
+		for name, id := range g.lock.ids {
+			used[id] = protoreflect.FullName(name)
+		}
+		for id, name := range g.lock.reserved {
+			used[id] = protoreflect.FullName(name)
 		}
-		buf.WriteString(tab)
-		buf.WriteString("}\n\n")
-
 	}
 
-	for _, msg := range file.GetMessageType() {
-		if msg.GetName() == "RequestMessage" {
+	for _, msg := range msgs {
+		id, ok := g.getPinnedId(msg)
+		if !ok {
+			continue
+		}
+		name := msg.Desc.FullName()
+		if !g.isCmdType(msg) {
+			g.fail("%s: %s has a (gocmd.id) option but is not a command", file.Desc.Path(), name)
+			continue
+		}
+		if !validCmdId(id) {
+			g.fail("%s: %s is pinned to the invalid id %d, expected 1 to 0x%X", file.Desc.Path(), name, id, math.MaxInt32)
 			continue
 		}
-		if msg.GetName() == "ResponseMessage" {
+		if other, dup := used[id]; dup && other != name {
+			g.fail("%s: %s is pinned to id 0x%X, which is already used by %s", file.Desc.Path(), name, id, other)
 			continue
 		}
-		buf.WriteString(tab)
-		buf.WriteString("export class ")
-		buf.WriteString(msg.GetName())
-		buf.WriteString(" {\n")
-
-		for _, field := range msg.GetField() {
-			buf.WriteString(tab)
-			buf.WriteString(tab)
-			buf.WriteString("public ")
-			buf.WriteString(field.GetName())
-			buf.WriteString(": ")
-
-			tsTypeName := g.getTsTypesMapping(field.GetType().String())
-			if tsTypeName == "" {
-				tsTypeName = field.GetTypeName()[strings.LastIndex(field.GetTypeName(), ".")+1:]
+		ids[name] = id
+		used[id] = name
+	}
+
+	if g.lock != nil {
+		for _, msg := range msgs {
+			if !g.isCmdType(msg) {
+				continue
 			}
-			if field.GetLabel().String() == "LABEL_REPEATED" {
-				buf.WriteString("Array<")
-				buf.WriteString(tsTypeName)
-				buf.WriteString(">")
-			} else {
-				buf.WriteString(tsTypeName)
+			if _, ok := ids[msg.Desc.FullName()]; ok {
+				continue
+			}
+			if id, ok := g.lock.get(string(msg.Desc.FullName())); ok {
+				if !validCmdId(id) {
+					g.fail("%s: the lock file gives %s the invalid id %d, expected 1 to 0x%X", file.Desc.Path(), msg.Desc.FullName(), id, math.MaxInt32)
+					continue
+				}
+				ids[msg.Desc.FullName()] = id
 			}
-			buf.WriteString(";\n")
 		}
-		buf.WriteString(tab)
-		buf.WriteString("}\n\n")
-
 	}
 
-	buf.WriteString("}\n")
-	response := new(plugin.CodeGeneratorResponse_File)
-	generatedFileName := "proto.model.ts"
-	fileContent := buf.String()
-	response.Name = &generatedFileName
-	response.Content = &fileContent
-	return response
-}
+	messageCount := len(file.Messages)
+	messageIDOffset := 0x1000 * g.getAppId(file)
+	for messageIDOffset < messageCount {
+		messageIDOffset <<= 4
+	}
 
-func (g *Generator) generateJavaFile(file *googleProto.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
-	buf := new(bytes.Buffer)
-	pkg, hasPkg := g.Params["pkg"]
-	if !hasPkg {
-		pkg = file.GetPackage()
+	messageID := messageIDOffset + 1
+	for _, msg := range g.cmdMessages(file) {
+		if _, ok := ids[msg.Desc.FullName()]; ok {
+			continue
+		}
+		for {
+			if _, taken := used[messageID]; !taken {
+				break
+			}
+			messageID++
+		}
+		if !validCmdId(messageID) {
+			g.fail("%s: no id left for %s, the app id %d is too large", file.Desc.Path(), msg.Desc.FullName(), g.getAppId(file))
+			break
+		}
+		ids[msg.Desc.FullName()] = messageID
+		used[messageID] = msg.Desc.FullName()
+		messageID++
 	}
 
-	tab := "    " // 4 spaces for tab by default
-	if _, ok := g.Params["usetabs"]; ok {
-		tab = "\t"
+	if g.lock != nil {
+		for name, id := range ids {
+			g.lock.set(string(name), id)
+		}
 	}
+	g.cmdIds[file.Desc.Path()] = ids
+	return ids
+}
 
+func (g *Generator) generateGoFileHeader(buf *bytes.Buffer, file *protogen.File) {
 	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
 	buf.WriteString("// source: ")
-	buf.WriteString(*file.Name)
+	buf.WriteString(file.Desc.Path())
 	buf.WriteByte('\n')
 	buf.WriteString("// DO NOT EDIT!\n")
 	buf.WriteByte('\n')
 	buf.WriteString("package ")
-	buf.WriteString(pkg)
-	buf.WriteString(";\n\n")
-	buf.WriteString("import java.util.Map;\n")
-	buf.WriteString("import java.util.HashMap;\n\n")
-	buf.WriteString("public class MessageTypes {\n")
-	messageCount := len(file.MessageType)
-	messageIDOffset := 0x1000 * g.getAppId(file)
-	for messageIDOffset < messageCount {
-		messageIDOffset <<= 4
-	}
-
-	messageID := messageIDOffset + 1
-	for _, msg := range file.GetMessageType() {
-		buf.WriteString(tab)
-		buf.WriteString("public static final int ")
-		buf.WriteString(strings.Title(msg.GetName()))
-		buf.WriteString(fmt.Sprintf(" = 0x%X;\n", messageID))
-		messageID++
-	}
-
-	buf.WriteString("\n")
-	buf.WriteString(tab)
-	buf.WriteString("private static Map<Integer, String> messageTypeToMessageNameMapping = new HashMap<Integer, String>();\n")
-	buf.WriteString("private static Map<String, Integer> messageNameToMessageTypeMapping = new HashMap<String, Integer>();\n\n")
-	buf.WriteString(tab)
-	buf.WriteString("static {\n")
-	for _, msg := range file.GetMessageType() {
-		buf.WriteString(tab)
-		buf.WriteString(tab)
-		buf.WriteString("messageTypeToMessageNameMapping.put(")
-		buf.WriteString(strings.Title(msg.GetName()))
-		buf.WriteString(", \"")
-		buf.WriteString(msg.GetName())
-		buf.WriteString("\");\n")
-		buf.WriteString(tab)
-		buf.WriteString(tab)
-		buf.WriteString("messageNameToMessageTypeMapping.put(\"")
-		buf.WriteString(msg.GetName())
-		buf.WriteString("\", ")
-		buf.WriteString(strings.Title(msg.GetName()))
-		buf.WriteString(");\n")
-	}
-	buf.WriteString(tab)
-	buf.WriteString("}\n\n")
-	buf.WriteString(tab)
-	buf.WriteString("public static String getMessageTypeName(int messageTypeId) {\n")
-	buf.WriteString(tab)
-	buf.WriteString(tab)
-	buf.WriteString("return messageTypeToMessageNameMapping.get(messageTypeId);\n")
-	buf.WriteString(tab)
-	buf.WriteString("}\n\n")
-	buf.WriteString(tab)
-	buf.WriteString("public static Integer getMessageTypeId(String messageTypeName) {\n")
-	buf.WriteString(tab)
-	buf.WriteString(tab)
-	buf.WriteString("return messageNameToMessageTypeMapping.get(messageTypeName);\n")
-	buf.WriteString(tab)
-	buf.WriteString("}\n")
-	buf.WriteString("}\n")
-	response := new(plugin.CodeGeneratorResponse_File)
-	generatedFileName := "MessageTypes.java"
-	fileContent := buf.String()
-	response.Name = &generatedFileName
-	response.Content = &fileContent
-	return response
+	buf.WriteString(string(file.GoPackageName))
+	buf.WriteByte('\n')
+	buf.WriteByte('\n')
 }
 
 //ByMsgTypeName sort all message types by name, so the protoId will be the same for each type of message in different runs
-type ByMsgTypeName []*googleProto.DescriptorProto
+type ByMsgTypeName []*protogen.Message
 
 func (t ByMsgTypeName) Len() int {
 	return len(t)
@@ -864,5 +548,5 @@ func (t ByMsgTypeName) Swap(i, j int) {
 }
 
 func (t ByMsgTypeName) Less(i, j int) bool {
-	return strings.Compare(*t[i].Name, *t[j].Name) < 0
+	return strings.Compare(string(t[i].Desc.Name()), string(t[j].Desc.Name())) < 0
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/funtoy/protoc-gen-gocmd/gocmd"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// testMessage is a message of the test file, pinned to id if set
+type testMessage struct {
+	name string
+	id   *int32
+}
+
+// pin returns the id of a pinned testMessage
+func pin(id int32) *int32 {
+	return &id
+}
+
+// testProto returns a proto3 file declaring the messages
+func testProto(name, pkg, goPackage string, msgs ...testMessage) *descriptorpb.FileDescriptorProto {
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String(name),
+		Package: proto.String(pkg),
+		Syntax:  proto.String("proto3"),
+	}
+	if goPackage != "" {
+		fd.Options = &descriptorpb.FileOptions{GoPackage: proto.String(goPackage)}
+	}
+	for _, m := range msgs {
+		msg := &descriptorpb.DescriptorProto{Name: proto.String(m.name)}
+		if m.id != nil {
+			msg.Options = new(descriptorpb.MessageOptions)
+			proto.SetExtension(msg.Options, gocmd.E_Id, *m.id)
+		}
+		fd.MessageType = append(fd.MessageType, msg)
+	}
+	return fd
+}
+
+// loadTestFiles loads the proto files with the params the way main does, all
+// of them being generated, and sorts them the way GenerateFiles does
+func loadTestFiles(t *testing.T, g *Generator, params string, fds ...*descriptorpb.FileDescriptorProto) []*protogen.File {
+	req := &pluginpb.CodeGeneratorRequest{Parameter: proto.String(params), ProtoFile: fds}
+	for _, fd := range fds {
+		req.FileToGenerate = append(req.FileToGenerate, fd.GetName())
+	}
+	if err := g.LoadRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	g.loadCmdRule()
+	g.loadRuntime()
+	for _, file := range g.Plugin.Files {
+		sort.Sort(ByMsgTypeName(file.Messages))
+	}
+	return g.Plugin.Files
+}
+
+// newTestFile loads a game.proto holding the messages with the params
+func newTestFile(t *testing.T, g *Generator, params string, msgs []testMessage) *protogen.File {
+	return loadTestFiles(t, g, params, testProto("game.proto", "game", "example.com/game", msgs...))[0]
+}
+
+func TestGetCmdIds(t *testing.T) {
+	tests := []struct {
+		name         string
+		msgs         []testMessage
+		lock         map[string]int
+		lockReserved map[int]string
+		want         map[string]int
+		// reserved are the ids the lock keeps reserved after the run
+		reserved map[int]string
+		err      string
+	}{{
+		name: "by name order",
+		msgs: []testMessage{{name: "LoginResponse"}, {name: "Player"}, {name: "LoginRequest"}, {name: "KickEvent"}},
+		want: map[string]int{"game.KickEvent": 0x1001, "game.LoginRequest": 0x1002, "game.LoginResponse": 0x1003},
+	}, {
+		name: "pinned",
+		msgs: []testMessage{{name: "LoginRequest", id: pin(0x2000)}, {name: "LoginResponse"}},
+		want: map[string]int{"game.LoginRequest": 0x2000, "game.LoginResponse": 0x1001},
+	}, {
+		name: "pinned on an assigned id",
+		msgs: []testMessage{{name: "LoginRequest"}, {name: "LoginResponse", id: pin(0x1001)}},
+		want: map[string]int{"game.LoginRequest": 0x1002, "game.LoginResponse": 0x1001},
+	}, {
+		name: "locked",
+		msgs: []testMessage{{name: "LoginRequest"}, {name: "LoginResponse"}},
+		lock: map[string]int{"game.LoginResponse": 0x1001},
+		want: map[string]int{"game.LoginRequest": 0x1002, "game.LoginResponse": 0x1001},
+	}, {
+		name: "inserted before locked",
+		msgs: []testMessage{{name: "AddRequest"}, {name: "LoginRequest"}, {name: "LoginResponse"}},
+		lock: map[string]int{"game.LoginRequest": 0x1001, "game.LoginResponse": 0x1002},
+		want: map[string]int{"game.AddRequest": 0x1003, "game.LoginRequest": 0x1001, "game.LoginResponse": 0x1002},
+	}, {
+		name: "deleted stays reserved",
+		msgs: []testMessage{{name: "LoginRequest"}},
+		lock: map[string]int{"game.LogoutRequest": 0x1001},
+		want: map[string]int{"game.LoginRequest": 0x1002},
+	}, {
+		name:     "pin overrides lock",
+		msgs:     []testMessage{{name: "AddRequest"}, {name: "LoginRequest", id: pin(0x2000)}},
+		lock:     map[string]int{"game.LoginRequest": 0x1001},
+		want:     map[string]int{"game.AddRequest": 0x1002, "game.LoginRequest": 0x2000},
+		reserved: map[int]string{0x1001: "game.LoginRequest"},
+	}, {
+		name:         "superseded stays reserved",
+		msgs:         []testMessage{{name: "AddRequest"}, {name: "LoginRequest"}},
+		lock:         map[string]int{"game.LoginRequest": 0x2000},
+		lockReserved: map[int]string{0x1001: "game.LoginRequest"},
+		want:         map[string]int{"game.AddRequest": 0x1002, "game.LoginRequest": 0x2000},
+		reserved:     map[int]string{0x1001: "game.LoginRequest"},
+	}, {
+		name:         "pinned back to a superseded id",
+		msgs:         []testMessage{{name: "LoginRequest", id: pin(0x1001)}},
+		lock:         map[string]int{"game.LoginRequest": 0x2000},
+		lockReserved: map[int]string{0x1001: "game.LoginRequest"},
+		want:         map[string]int{"game.LoginRequest": 0x1001},
+		reserved:     map[int]string{0x2000: "game.LoginRequest"},
+	}, {
+		name:         "pinned on a superseded id",
+		msgs:         []testMessage{{name: "AddRequest", id: pin(0x1001)}},
+		lock:         map[string]int{"game.LoginRequest": 0x2000},
+		lockReserved: map[int]string{0x1001: "game.LoginRequest"},
+		err:          "game.AddRequest is pinned to id 0x1001, which is already used by game.LoginRequest",
+	}, {
+		name: "pinned twice",
+		msgs: []testMessage{{name: "LoginRequest", id: pin(0x2000)}, {name: "LoginResponse", id: pin(0x2000)}},
+		err:  "game.LoginResponse is pinned to id 0x2000, which is already used by game.LoginRequest",
+	}, {
+		name: "pinned on a locked id",
+		msgs: []testMessage{{name: "LoginRequest", id: pin(0x1001)}},
+		lock: map[string]int{"game.LogoutRequest": 0x1001},
+		err:  "game.LoginRequest is pinned to id 0x1001, which is already used by game.LogoutRequest",
+	}, {
+		name: "pinned not a command",
+		msgs: []testMessage{{name: "Player", id: pin(0x2000)}},
+		err:  "game.Player has a (gocmd.id) option but is not a command",
+	}, {
+		name: "pinned to zero",
+		msgs: []testMessage{{name: "LoginRequest", id: pin(0)}},
+		err:  "game.LoginRequest is pinned to the invalid id 0",
+	}, {
+		name: "pinned negative",
+		msgs: []testMessage{{name: "LoginRequest", id: pin(-1)}},
+		err:  "game.LoginRequest is pinned to the invalid id -1",
+	}, {
+		name: "locked out of range",
+		msgs: []testMessage{{name: "LoginRequest"}},
+		lock: map[string]int{"game.LoginRequest": 1 << 40},
+		err:  "the lock file gives game.LoginRequest the invalid id 1099511627776",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator()
+			file := newTestFile(t, g, "cmd", tt.msgs)
+			if tt.lock != nil {
+				g.lock = &cmdLock{ids: tt.lock, reserved: make(map[int]string)}
+				for id, name := range tt.lockReserved {
+					g.lock.reserved[id] = name
+				}
+			}
+			ids := g.getCmdIds(file)
+			if tt.err != "" {
+				if !g.Failed() || !strings.Contains(strings.Join(g.errors, "\n"), tt.err) {
+					t.Fatalf("errors %q, want %q", g.errors, tt.err)
+				}
+				return
+			}
+			if g.Failed() {
+				t.Fatalf("unexpected errors %q", g.errors)
+			}
+			want := make(map[protoreflect.FullName]int)
+			for name, id := range tt.want {
+				want[protoreflect.FullName(name)] = id
+			}
+			if len(ids) != len(want) {
+				t.Fatalf("ids %v, want %v", ids, want)
+			}
+			for name, id := range want {
+				if ids[name] != id {
+					t.Errorf("%s: id 0x%x, want 0x%x", name, ids[name], id)
+				}
+			}
+			if g.lock != nil {
+				for name, id := range want {
+					if locked, _ := g.lock.get(string(name)); locked != id {
+						t.Errorf("%s: locked 0x%x, want 0x%x", name, locked, id)
+					}
+				}
+				if len(g.lock.reserved) != len(tt.reserved) {
+					t.Errorf("reserved %v, want %v", g.lock.reserved, tt.reserved)
+				}
+				for id, name := range tt.reserved {
+					if g.lock.reserved[id] != name {
+						t.Errorf("0x%x: reserved for %q, want %q", id, g.lock.reserved[id], name)
+					}
+				}
+			}
+		})
+	}
+}
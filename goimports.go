@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// goImports collects the packages referenced by a generated go file, so that
+// the import block is written once the body is known. Every package gets a
+// name unique in the file, the package of the file itself is never imported.
+type goImports struct {
+	g       *Generator
+	file    *protogen.File
+	local   protogen.GoImportPath
+	known   map[protogen.GoImportPath]protogen.GoPackageName
+	sources map[protogen.GoImportPath]string // a proto file of each package
+	names   map[protogen.GoImportPath]string
+	taken   map[string]bool
+	failed  map[protogen.GoImportPath]bool
+}
+
+// newGoImports returns the imports of a go file generated for the proto file
+func (g *Generator) newGoImports(file *protogen.File) *goImports {
+	known := make(map[protogen.GoImportPath]protogen.GoPackageName)
+	sources := make(map[protogen.GoImportPath]string)
+	for _, f := range g.Plugin.Files {
+		known[f.GoImportPath] = f.GoPackageName
+		if _, ok := sources[f.GoImportPath]; !ok {
+			sources[f.GoImportPath] = f.Desc.Path()
+		}
+	}
+	return &goImports{
+		g:       g,
+		file:    file,
+		local:   file.GoImportPath,
+		known:   known,
+		sources: sources,
+		names:   make(map[protogen.GoImportPath]string),
+		taken:   make(map[string]bool),
+		failed:  make(map[protogen.GoImportPath]bool),
+	}
+}
+
+// use records an import and returns the name to qualify its identifiers with
+func (im *goImports) use(importPath protogen.GoImportPath) string {
+	if name, ok := im.names[importPath]; ok {
+		return name
+	}
+	base := string(im.known[importPath])
+	if base == "" {
+		base = strings.NewReplacer(".", "_", "-", "_").Replace(path.Base(string(importPath)))
+	}
+	name := base
+	for i := 1; im.taken[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	im.names[importPath] = name
+	im.taken[name] = true
+	return name
+}
+
+// qualify returns the identifier as referenced from the generated file. The
+// packages of the files with a bare go_package have no import path, so their
+// identifiers can only be used from the same package.
+func (im *goImports) qualify(ident protogen.GoIdent) string {
+	if ident.GoImportPath == im.local {
+		return ident.GoName
+	}
+	if isLegacyImportPath(ident.GoImportPath) {
+		if !im.failed[ident.GoImportPath] {
+			im.failed[ident.GoImportPath] = true
+			source := im.sources[ident.GoImportPath]
+			im.g.fail("%s: %s refers to %s from %s, which has no go import path: set option go_package to a full import path in %s or pass M%s=<import path>",
+				im.file.Desc.Path(), ident.GoName, im.known[ident.GoImportPath], source, source, source)
+		}
+		return ident.GoName
+	}
+	return im.use(ident.GoImportPath) + "." + ident.GoName
+}
+
+// write the import block, the standard library first, nothing when no package
+// is used
+func (im *goImports) write(buf *bytes.Buffer) {
+	if len(im.names) == 0 {
+		return
+	}
+	var std, others []string
+	for importPath := range im.names {
+		if strings.Contains(strings.SplitN(string(importPath), "/", 2)[0], ".") {
+			others = append(others, string(importPath))
+		} else {
+			std = append(std, string(importPath))
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+
+	buf.WriteString("import (\n")
+	for i, group := range [][]string{std, others} {
+		if i > 0 && len(std) > 0 && len(others) > 0 {
+			buf.WriteByte('\n')
+		}
+		for _, importPath := range group {
+			buf.WriteByte('\t')
+			if name := im.names[protogen.GoImportPath(importPath)]; name != path.Base(importPath) {
+				buf.WriteString(name)
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(strconv.Quote(importPath))
+			buf.WriteByte('\n')
+		}
+	}
+	buf.WriteString(")\n\n")
+}
+
+// goPackageIdents returns the identifiers declared by the messages and enums
+// of the go package of the file, each with the proto element declaring it
+func (g *Generator) goPackageIdents(file *protogen.File) map[string]string {
+	idents := make(map[string]string)
+	for _, f := range g.Plugin.Files {
+		if f.GoImportPath != file.GoImportPath {
+			continue
+		}
+		for _, msg := range g.allMessages(f) {
+			idents[msg.GoIdent.GoName] = "the message " + string(msg.Desc.FullName())
+		}
+		for _, enum := range g.allEnums(f) {
+			idents[enum.GoIdent.GoName] = "the enum " + string(enum.Desc.FullName())
+			for _, value := range enum.Values {
+				idents[value.GoIdent.GoName] = "the enum value " + string(value.Desc.FullName())
+			}
+		}
+	}
+	return idents
+}
+
+// checkGoIdents fails for each identifier written by the target into the go
+// package of the file that a message or an enum of the package already takes
+func (g *Generator) checkGoIdents(file *protogen.File, target string, names []string) {
+	taken := g.goPackageIdents(file)
+	for _, name := range names {
+		if other, ok := taken[name]; ok {
+			g.fail("%s: the %s target declares %s, which collides with %s, rename it", file.Desc.Path(), target, name, other)
+		}
+	}
+}
+
+// goPackageFiles returns the generated proto files of the go package of the
+// file, in the order of the request
+func (g *Generator) goPackageFiles(file *protogen.File) []*protogen.File {
+	var files []*protogen.File
+	for _, f := range g.Plugin.Files {
+		if f.Generate && f.GoImportPath == file.GoImportPath {
+			files = append(files, f)
+		}
+	}
+	return files
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestLegacyGoPackages(t *testing.T) {
+	tests := []struct {
+		name      string
+		pkg       string
+		goPackage string
+		params    string
+		want      string // the M param added, if any
+		err       string
+	}{{
+		name: "a/x.proto", pkg: "x", goPackage: "game",
+		want: "Ma/x.proto=./a/game/..;game",
+	}, {
+		name: "a/x.proto", pkg: "x", goPackage: "game;gamepb",
+		want: "Ma/x.proto=./a/gamepb/..;gamepb",
+	}, {
+		name: "x.proto", pkg: "x", goPackage: "game",
+		want: "Mx.proto=./game/..;game",
+	}, {
+		name: "a/x.proto", pkg: "game.v1",
+		want: "Ma/x.proto=./a/game_v1/..;game_v1",
+	}, {
+		name: "a/x.proto", pkg: "x", goPackage: "type",
+		want: "Ma/x.proto=./a/_type/..;_type",
+	}, {
+		name: "a/x.proto", pkg: "x", goPackage: "example.com/x",
+	}, {
+		name: "a/x.proto", pkg: "x", goPackage: "game", params: "Ma/x.proto=example.com/x",
+	}, {
+		name: "a/x.proto",
+		err:  "a/x.proto: cannot determine the go package",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.goPackage, func(t *testing.T) {
+			g := NewGenerator()
+			fd := testProto(tt.name, tt.pkg, tt.goPackage)
+			if tt.pkg == "" {
+				fd.Package = nil
+			}
+			req := &pluginpb.CodeGeneratorRequest{ProtoFile: []*descriptorpb.FileDescriptorProto{fd}}
+			if tt.params != "" {
+				req.Parameter = proto.String(tt.params)
+			}
+			got := g.legacyGoPackages(req).GetParameter()
+			if tt.err != "" {
+				if !g.Failed() || !strings.Contains(strings.Join(g.errors, "\n"), tt.err) {
+					t.Fatalf("errors %q, want %q", g.errors, tt.err)
+				}
+				return
+			}
+			want := tt.params
+			if tt.want != "" && want != "" {
+				want = tt.want + "," + want
+			} else if tt.want != "" {
+				want = tt.want
+			}
+			if got != want {
+				t.Fatalf("params %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGogoGoPackages(t *testing.T) {
+	timestamp := "Mgoogle/protobuf/timestamp.proto=" + gogoTypesPackage + ";types"
+	duration := "Mgoogle/protobuf/duration.proto=" + gogoTypesPackage + ";types"
+	tests := []struct {
+		params  string
+		mapped  []string
+		missing []string
+	}{{
+		params: "",
+		mapped: []string{timestamp, duration},
+	}, {
+		params: "runtime=gogo,pack",
+		mapped: []string{timestamp, duration},
+	}, {
+		params:  "runtime=golang,pack",
+		missing: []string{timestamp, duration},
+	}, {
+		params:  "runtime=vtproto",
+		missing: []string{timestamp},
+	}, {
+		params:  "Mgoogle/protobuf/timestamp.proto=example.com/ts",
+		mapped:  []string{duration},
+		missing: []string{timestamp},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.params, func(t *testing.T) {
+			req := &pluginpb.CodeGeneratorRequest{Parameter: proto.String(tt.params)}
+			got := NewGenerator().gogoGoPackages(req).GetParameter()
+			if !strings.HasSuffix(got, tt.params) {
+				t.Errorf("params %q do not end with the user params %q", got, tt.params)
+			}
+			params := strings.Split(got, ",")
+			has := make(map[string]bool)
+			for _, param := range params {
+				has[param] = true
+			}
+			for _, param := range tt.mapped {
+				if !has[param] {
+					t.Errorf("%s missing from %q", param, params)
+				}
+			}
+			for _, param := range tt.missing {
+				if has[param] {
+					t.Errorf("unexpected %s in %q", param, params)
+				}
+			}
+		})
+	}
+}
+
+func TestGoImports(t *testing.T) {
+	g := NewGenerator()
+	files := loadTestFiles(t, g, "cmd",
+		testProto("game.proto", "game", "example.com/game", testMessages("LoginRequest")...),
+		testProto("a/common.proto", "a.common", "example.com/a/common", testMessages("Item")...),
+		testProto("b/common.proto", "b.common", "example.com/b/common;common", testMessages("Item")...),
+		testProto("legacy/legacy.proto", "legacy", "legacy", testMessages("Player")...),
+	)
+	imports := g.newGoImports(files[0])
+
+	for _, tt := range []struct {
+		ident protogen.GoIdent
+		want  string
+	}{
+		{files[0].Messages[0].GoIdent, "LoginRequest"},
+		{files[1].Messages[0].GoIdent, "common.Item"},
+		{files[2].Messages[0].GoIdent, "common1.Item"},
+		{files[1].Messages[0].GoIdent, "common.Item"},
+		{protogen.GoIdent{GoName: "Writer", GoImportPath: "io"}, "io.Writer"},
+		{protogen.GoIdent{GoName: "Foo", GoImportPath: "example.com/go-kit.v2"}, "go_kit_v2.Foo"},
+	} {
+		if got := imports.qualify(tt.ident); got != tt.want {
+			t.Errorf("qualify(%s) %s, want %s", tt.ident, got, tt.want)
+		}
+	}
+	if g.Failed() {
+		t.Fatalf("unexpected errors %q", g.errors)
+	}
+
+	buf := new(bytes.Buffer)
+	imports.write(buf)
+	want := `import (
+	"io"
+
+	"example.com/a/common"
+	common1 "example.com/b/common"
+	go_kit_v2 "example.com/go-kit.v2"
+)
+
+`
+	if buf.String() != want {
+		t.Errorf("imports\n%s\nwant\n%s", buf, want)
+	}
+
+	// the legacy go packages have no import path
+	imports.qualify(files[3].Messages[0].GoIdent)
+	if want := "game.proto: Player refers to legacy from legacy/legacy.proto, which has no go import path"; !strings.Contains(strings.Join(g.errors, "\n"), want) {
+		t.Errorf("errors %q, want %q", g.errors, want)
+	}
+}
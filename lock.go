@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+const defaultLockFile = "gocmd.lock.json"
+
+// cmdLock keeps the command ids assigned by previous runs, keyed by the full
+// message name. Entries are never removed, so the id of a deleted message
+// stays reserved and is not handed out again. The previous id of a message
+// given a new one, e.g. by a (gocmd.id) option, is kept reserved as well.
+type cmdLock struct {
+	path     string
+	ids      map[string]int
+	reserved map[int]string // previous owner of the superseded ids
+	dirty    bool
+}
+
+// lockFile is the content of the lock file
+type lockFile struct {
+	Ids      map[string]int `json:"ids"`
+	Reserved map[int]string `json:"reserved,omitempty"`
+}
+
+// loadCmdLock read the lock file, a missing file is treated as an empty lock.
+// The lock files written before the reserved ids are a plain map of ids.
+func loadCmdLock(path string) (*cmdLock, error) {
+	l := &cmdLock{path: path, ids: make(map[string]int), reserved: make(map[int]string)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids map[string]int
+	if json.Unmarshal(data, &ids) == nil {
+		if ids != nil {
+			l.ids = ids
+		}
+		return l, nil
+	}
+	var file lockFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Ids != nil {
+		l.ids = file.Ids
+	}
+	if file.Reserved != nil {
+		l.reserved = file.Reserved
+	}
+	return l, nil
+}
+
+func (l *cmdLock) get(name string) (int, bool) {
+	id, ok := l.ids[name]
+	return id, ok
+}
+
+// set records the id of the message, its previous id if any stays reserved
+func (l *cmdLock) set(name string, id int) {
+	old, ok := l.ids[name]
+	if ok && old == id {
+		return
+	}
+	if l.reserved == nil {
+		l.reserved = make(map[int]string)
+	}
+	if ok {
+		l.reserved[old] = name
+	}
+	delete(l.reserved, id)
+	l.ids[name] = id
+	l.dirty = true
+}
+
+// save write the lock file back if any id was added or changed
+func (l *cmdLock) save() error {
+	if !l.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(lockFile{Ids: l.ids, Reserved: l.reserved}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return ioutil.WriteFile(l.path, data, 0644)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCmdLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), defaultLockFile)
+
+	// lock files written before the reserved ids are a plain map
+	if err := ioutil.WriteFile(path, []byte(`{"game.LoginRequest": 4097}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := loadCmdLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := l.get("game.LoginRequest"); id != 0x1001 {
+		t.Fatalf("id 0x%x, want 0x1001", id)
+	}
+
+	l.set("game.LoginRequest", 0x2000)
+	if err := l.save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"ids\": {\n    \"game.LoginRequest\": 8192\n  },\n  \"reserved\": {\n    \"4097\": \"game.LoginRequest\"\n  }\n}\n"
+	if string(data) != want {
+		t.Fatalf("lock file\n%s\nwant\n%s", data, want)
+	}
+
+	l, err = loadCmdLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := l.get("game.LoginRequest"); id != 0x2000 || l.reserved[0x1001] != "game.LoginRequest" {
+		t.Fatalf("ids %v, reserved %v", l.ids, l.reserved)
+	}
+}
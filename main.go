@@ -6,7 +6,8 @@ import (
 	"os"
 	"strings"
 
-	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
 )
 
 func errorOut(err error, msgs ...string) {
@@ -15,12 +16,6 @@ func errorOut(err error, msgs ...string) {
 	os.Exit(1)
 }
 
-func failWithMessage(msgs ...string) {
-	s := strings.Join(msgs, " ")
-	log.Print("protoc-gen-gocmd: error:", s)
-	os.Exit(1)
-}
-
 func main() {
 	g := NewGenerator()
 	data, err := ioutil.ReadAll(os.Stdin)
@@ -28,16 +23,24 @@ func main() {
 		errorOut(err, "reading input")
 	}
 
-	if err := proto.Unmarshal(data, g.Request); err != nil {
+	req := new(pluginpb.CodeGeneratorRequest)
+	if err := proto.Unmarshal(data, req); err != nil {
 		errorOut(err, "parsing input proto")
 	}
 
-	if len(g.Request.FileToGenerate) == 0 {
-		failWithMessage("no files to generate")
+	if len(req.FileToGenerate) == 0 {
+		g.fail("no files to generate")
+	} else if err := g.LoadRequest(req); err != nil {
+		g.fail("%v", err)
+	} else if err := g.LoadLock(); err != nil {
+		g.fail("loading lock file: %v", err)
+	} else if !g.Failed() {
+		g.GenerateFiles()
+		if err := g.SaveLock(); err != nil {
+			g.fail("saving lock file: %v", err)
+		}
 	}
-
-	g.LoadParams()
-	g.GenerateFiles()
+	g.ReportErrors()
 
 	data, err = proto.Marshal(g.Response)
 	if err != nil {
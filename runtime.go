@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// runtime=gogo|golang|vtproto selects the protobuf runtime the generated go
+// code is written against: gogo (the default) and vtproto call the methods
+// generated on the messages, golang calls google.golang.org/protobuf/proto.
+const optionRuntime = "runtime"
+
+const (
+	runtimeGogo    = "gogo"
+	runtimeGolang  = "golang"
+	runtimeVtproto = "vtproto"
+)
+
+const (
+	gogoProtoPackage   = "github.com/gogo/protobuf/proto"
+	gogoTypesPackage   = "github.com/gogo/protobuf/types"
+	golangProtoPackage = "google.golang.org/protobuf/proto"
+)
+
+// gogoWellKnownFiles are the well-known proto files whose messages gogo
+// generates in its types package
+var gogoWellKnownFiles = []string{
+	"google/protobuf/any.proto",
+	"google/protobuf/api.proto",
+	"google/protobuf/duration.proto",
+	"google/protobuf/empty.proto",
+	"google/protobuf/field_mask.proto",
+	"google/protobuf/source_context.proto",
+	"google/protobuf/struct.proto",
+	"google/protobuf/timestamp.proto",
+	"google/protobuf/type.proto",
+	"google/protobuf/wrappers.proto",
+}
+
+var runtimes = []string{runtimeGogo, runtimeGolang, runtimeVtproto}
+
+func init() {
+	RegisterOption(optionRuntime)
+}
+
+// loadRuntime reads the runtime param
+func (g *Generator) loadRuntime() {
+	g.runtime = g.Params[optionRuntime]
+	if g.runtime == "" {
+		g.runtime = runtimeGogo
+	}
+	for _, name := range runtimes {
+		if g.runtime == name {
+			return
+		}
+	}
+	g.fail("runtime: unknown value %q, expected one of %s", g.runtime, strings.Join(runtimes, ","))
+}
+
+// gogoGoPackages maps the well-known proto files to the gogo types package
+// with the gogo runtime, the way the gogo messages refer to them. An M param
+// of the user for one of the files is kept.
+func (g *Generator) gogoGoPackages(req *pluginpb.CodeGeneratorRequest) *pluginpb.CodeGeneratorRequest {
+	if runtime, _ := requestParam(req, optionRuntime); runtime != "" && runtime != runtimeGogo {
+		return req
+	}
+	mapped := mappedFiles(req)
+	var params []string
+	for _, name := range gogoWellKnownFiles {
+		if !mapped[name] {
+			params = append(params, "M"+name+"="+gogoTypesPackage+";types")
+		}
+	}
+	return withParams(req, params)
+}
+
+// goMarshal returns the call encoding the message m
+func (g *Generator) goMarshal(m string, imports *goImports) string {
+	switch g.runtime {
+	case runtimeGolang:
+		return imports.use(golangProtoPackage) + ".Marshal(" + m + ")"
+	case runtimeVtproto:
+		return m + ".MarshalVT()"
+	default:
+		return m + ".Marshal()"
+	}
+}
+
+// goUnmarshal returns the call decoding data into the message m
+func (g *Generator) goUnmarshal(m, data string, imports *goImports) string {
+	switch g.runtime {
+	case runtimeGolang:
+		return imports.use(golangProtoPackage) + ".Unmarshal(" + data + ", " + m + ")"
+	case runtimeVtproto:
+		return m + ".UnmarshalVT(" + data + ")"
+	default:
+		return m + ".Unmarshal(" + data + ")"
+	}
+}
+
+// goMessageMethods returns the body of the interface implemented by all the
+// messages, enough to decode them
+func (g *Generator) goMessageMethods(imports *goImports) string {
+	switch g.runtime {
+	case runtimeGolang:
+		return imports.use(golangProtoPackage) + ".Message"
+	case runtimeVtproto:
+		return "UnmarshalVT(data []byte) error"
+	default:
+		return "Unmarshal(data []byte) error"
+	}
+}
+
+// goProtoHelpers returns the package providing the proto2 pointer helpers,
+// e.g. proto.Int32
+func (g *Generator) goProtoHelpers(imports *goImports) string {
+	if g.runtime == runtimeGogo {
+		return imports.use(gogoProtoPackage)
+	}
+	return imports.use(golangProtoPackage)
+}
+
+// goWellKnown returns the expression converting the argument to the
+// well-known message of the package pkg. The golang packages provide
+// constructors, the gogo types package only the message structs.
+func (g *Generator) goWellKnown(wkt *wellKnownType, argument, pkg string) string {
+	if g.runtime == runtimeGogo || wkt.goNew == "" {
+		return fmt.Sprintf(wkt.goConvert, argument, pkg)
+	}
+	return fmt.Sprintf(wkt.goNew, argument, pkg)
+}
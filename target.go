@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Target generates one kind of output from the proto files. Targets register
+// themselves from an init function, so a new target only needs a new file.
+type Target interface {
+	// Name is the plugin param that enables the target, e.g. cmd
+	Name() string
+	// PerPackage tells whether the target writes one output for the whole proto
+	// package instead of one per proto file
+	PerPackage() bool
+	// Generate returns the files generated for the proto file
+	Generate(g *Generator, file *protogen.File) []*pluginpb.CodeGeneratorResponse_File
+}
+
+var (
+	targets = make(map[string]Target)
+	options = map[string]bool{"usetabs": true, "lock": true}
+)
+
+// RegisterTarget make a target available through the plugin params
+func RegisterTarget(t Target) {
+	if _, dup := targets[t.Name()]; dup {
+		panic("protoc-gen-gocmd: target " + t.Name() + " registered twice")
+	}
+	targets[t.Name()] = t
+}
+
+// RegisterOption declare a plugin param understood by a target, e.g. pkg for
+// the java target, so that it is not reported as an unknown param
+func RegisterOption(name string) {
+	options[name] = true
+}
+
+// targetNames returns the names of all registered targets in sorted order
+func targetNames() []string {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// enabledTargets returns the targets selected by the params in name order,
+// every param must be either a target or a registered option
+func (g *Generator) enabledTargets() []Target {
+	var enabled []Target
+	for _, name := range targetNames() {
+		if _, ok := g.Params[name]; ok {
+			enabled = append(enabled, targets[name])
+		}
+	}
+	params := make([]string, 0, len(g.Params))
+	for name := range g.Params {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		if _, ok := targets[name]; !ok && !options[name] {
+			g.fail("unknown parameter %q, available targets: %s", name, strings.Join(targetNames(), ","))
+		}
+	}
+	if len(enabled) == 0 {
+		g.fail("please specify which files to be generated, available targets: %s", strings.Join(targetNames(), ","))
+	}
+	return enabled
+}
+
+// fileTarget adapts a generate function writing a single file to Target
+type fileTarget struct {
+	name       string
+	perPackage bool
+	generate   func(g *Generator, file *protogen.File) *pluginpb.CodeGeneratorResponse_File
+}
+
+func (t *fileTarget) Name() string {
+	return t.name
+}
+
+func (t *fileTarget) PerPackage() bool {
+	return t.perPackage
+}
+
+func (t *fileTarget) Generate(g *Generator, file *protogen.File) []*pluginpb.CodeGeneratorResponse_File {
+	return []*pluginpb.CodeGeneratorResponse_File{t.generate(g, file)}
+}
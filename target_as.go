@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const targetAs = "as"
+
+func init() {
+	RegisterTarget(&fileTarget{name: targetAs, perPackage: true, generate: (*Generator).generateAsFile})
+	RegisterOption("asns")
+}
+
+func (g *Generator) generateAsFile(file *protogen.File) *pluginpb.CodeGeneratorResponse_File {
+	buf := new(bytes.Buffer)
+	ns, hasNs := g.Params["asns"]
+	if !hasNs {
+		ns = string(file.Desc.Package())
+	}
+
+	tab := "    " // 4 spaces for tab by default
+	if _, ok := g.Params["usetabs"]; ok {
+		tab = "\t"
+	}
+
+	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
+	buf.WriteString("// source: ")
+	buf.WriteString(file.Desc.Path())
+	buf.WriteByte('\n')
+	buf.WriteString("// DO NOT EDIT!\n")
+	buf.WriteByte('\n')
+	buf.WriteString("package ")
+	buf.WriteString(ns)
+	buf.WriteString("\n{\n")
+	buf.WriteString(tab)
+	buf.WriteString("public class ProtocolType{\n")
+	cmdIds := g.getCmdIds(file)
+	for _, msg := range g.allMessages(file) {
+		if !g.isCmdType(msg) {
+			continue
+		}
+		writeBlockDoc(buf, tab+tab, commentLines(msg.Comments))
+		buf.WriteString(tab)
+		buf.WriteString(tab)
+		buf.WriteString("public static const ")
+		buf.WriteString(strings.Title(flatName(msg.Desc)))
+		buf.WriteString(fmt.Sprintf(" : int = 0x%X;\n", cmdIds[msg.Desc.FullName()]))
+	}
+
+	buf.WriteString(tab)
+	buf.WriteString("}\n}\n")
+	response := new(pluginpb.CodeGeneratorResponse_File)
+	generatedFileName := "ProtocolType.as"
+	fileContent := buf.String()
+	response.Name = &generatedFileName
+	response.Content = &fileContent
+	return response
+}
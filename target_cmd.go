@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const targetCmd = "cmd"
+
+func init() {
+	RegisterTarget(&fileTarget{name: targetCmd, generate: (*Generator).generateCmdFile})
+}
+
+// cmdPackageIdentifiers are the package level identifiers written by the cmd
+// target besides the constants of the commands
+var cmdPackageIdentifiers = []string{
+	"CmdName", "ResponseCmdFor", "RequestCmdFor", "Cmd", "CmdKind", "CmdKindUnknown", "CmdKindCustom",
+	"CmdKindRequest", "CmdKindResponse", "CmdKindEvent", "ParseCmd", "AllCmds",
+}
+
+func (g *Generator) generateCmdFile(file *protogen.File) *pluginpb.CodeGeneratorResponse_File {
+	buf := new(bytes.Buffer)
+	imports := g.newGoImports(file)
+	// the declarations are package level, a second file of the go package
+	// would declare them again
+	if first := g.goPackageFiles(file)[0]; first != file {
+		g.fail("%s: target %s writes one file per go package, but %s is already generated for go package %s",
+			file.Desc.Path(), targetCmd, first.Desc.Path(), string(file.GoImportPath))
+	}
+	g.checkGoIdents(file, targetCmd, cmdPackageIdentifiers)
+	cmdIds := g.getCmdIds(file)
+	for _, v := range g.allMessages(file) {
+		if !g.isCmdType(v) {
+			continue
+		}
+		writeGoDoc(buf, "", commentLines(v.Comments))
+		buf.WriteString("const Cmd_")
+		buf.WriteString(v.GoIdent.GoName)
+		buf.WriteString(fmt.Sprintf(" = 0x%X\n", cmdIds[v.Desc.FullName()]))
+	}
+
+	buf.WriteByte('\n')
+	buf.WriteString("var CmdName = map[int32]string{\n")
+
+	for _, v := range g.allMessages(file) {
+		if !g.isCmdType(v) {
+			continue
+		}
+		buf.WriteString("\tCmd_")
+		buf.WriteString(v.GoIdent.GoName)
+		buf.WriteString(": ")
+		buf.WriteString(strconv.Quote(g.cmdLabel(v)))
+		buf.WriteString(",\n")
+	}
+	buf.WriteString("}\n")
+
+	pairs := g.cmdPairs(file)
+	buf.WriteString("\nvar responseCmds = map[int32]int32{\n")
+	for _, pair := range pairs {
+		buf.WriteString("\tCmd_" + pair.request.GoIdent.GoName + ": Cmd_" + pair.response.GoIdent.GoName + ",\n")
+	}
+	buf.WriteString("}\n")
+	buf.WriteString("\nvar requestCmds = map[int32]int32{\n")
+	for _, pair := range pairs {
+		if pair.reverse {
+			buf.WriteString("\tCmd_" + pair.response.GoIdent.GoName + ": Cmd_" + pair.request.GoIdent.GoName + ",\n")
+		}
+	}
+	buf.WriteString("}\n")
+	buf.WriteString("\n// ResponseCmdFor returns the command of the response to the request command\n")
+	buf.WriteString("func ResponseCmdFor(req int32) (int32, bool) {\n")
+	buf.WriteString("\tresp, ok := responseCmds[req]\n")
+	buf.WriteString("\treturn resp, ok\n")
+	buf.WriteString("}\n")
+	buf.WriteString("\n// RequestCmdFor returns the command of the request answered by the response command\n")
+	buf.WriteString("func RequestCmdFor(resp int32) (int32, bool) {\n")
+	buf.WriteString("\treq, ok := requestCmds[resp]\n")
+	buf.WriteString("\treturn req, ok\n")
+	buf.WriteString("}\n")
+
+	g.generateCmdType(buf, file, imports)
+
+	content := new(bytes.Buffer)
+	g.generateGoFileHeader(content, file)
+	imports.write(content)
+	content.Write(buf.Bytes())
+
+	response := new(pluginpb.CodeGeneratorResponse_File)
+	generatedFileName := g.filename(file) + ".cmd.go"
+	fileContent := content.String()
+	response.Name = &generatedFileName
+	response.Content = &fileContent
+	return response
+}
+
+// generateCmdType writes the Cmd type wrapping the command ids, with a typed
+// constant for each command next to the untyped Cmd_X ones
+func (g *Generator) generateCmdType(buf *bytes.Buffer, file *protogen.File, imports *goImports) {
+	cmdIds := g.getCmdIds(file)
+	msgs := g.cmdMessages(file)
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return cmdIds[msgs[i].Desc.FullName()] < cmdIds[msgs[j].Desc.FullName()]
+	})
+
+	g.checkCmdConsts(file, msgs)
+	buf.WriteString("\n// Cmd is the id of a command\n")
+	buf.WriteString("type Cmd int32\n\n")
+	if len(msgs) > 0 {
+		buf.WriteString("const (\n")
+		for _, msg := range msgs {
+			writeGoDoc(buf, "\t", commentLines(msg.Comments))
+			buf.WriteString("\tCmd" + msg.GoIdent.GoName + " Cmd = Cmd_" + msg.GoIdent.GoName + "\n")
+		}
+		buf.WriteString(")\n\n")
+	}
+
+	buf.WriteString("// CmdKind is the kind of a command\n")
+	buf.WriteString("type CmdKind int32\n\n")
+	buf.WriteString("const (\n")
+	buf.WriteString("\tCmdKindUnknown CmdKind = iota\n")
+	buf.WriteString("\tCmdKindCustom\n")
+	buf.WriteString("\tCmdKindRequest\n")
+	buf.WriteString("\tCmdKindResponse\n")
+	buf.WriteString("\tCmdKindEvent\n")
+	buf.WriteString(")\n\n")
+	buf.WriteString("func (k CmdKind) String() string {\n")
+	buf.WriteString("\tswitch k {\n")
+	buf.WriteString("\tcase CmdKindRequest:\n\t\treturn \"request\"\n")
+	buf.WriteString("\tcase CmdKindResponse:\n\t\treturn \"response\"\n")
+	buf.WriteString("\tcase CmdKindEvent:\n\t\treturn \"event\"\n")
+	buf.WriteString("\tcase CmdKindCustom:\n\t\treturn \"custom\"\n")
+	buf.WriteString("\tdefault:\n\t\treturn \"unknown\"\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("var allCmds = []Cmd{\n")
+	for _, msg := range msgs {
+		buf.WriteString("\tCmd" + msg.GoIdent.GoName + ",\n")
+	}
+	buf.WriteString("}\n\n")
+	buf.WriteString("var cmdNames = map[Cmd]string{\n")
+	for _, msg := range msgs {
+		buf.WriteString("\tCmd" + msg.GoIdent.GoName + ": \"" + msg.GoIdent.GoName + "\",\n")
+	}
+	buf.WriteString("}\n\n")
+	buf.WriteString("var cmdValues = map[string]Cmd{\n")
+	for _, msg := range msgs {
+		buf.WriteString("\t\"" + msg.GoIdent.GoName + "\": Cmd" + msg.GoIdent.GoName + ",\n")
+	}
+	buf.WriteString("}\n\n")
+	buf.WriteString("var cmdKinds = map[Cmd]CmdKind{\n")
+	for _, msg := range msgs {
+		kind, _ := g.cmdKind(msg)
+		buf.WriteString("\tCmd" + msg.GoIdent.GoName + ": CmdKind" + strings.Title(string(kind)) + ",\n")
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// String returns the name of the command, e.g. LoginRequest\n")
+	buf.WriteString("func (c Cmd) String() string {\n")
+	buf.WriteString("\tif name, ok := cmdNames[c]; ok {\n")
+	buf.WriteString("\t\treturn name\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn " + imports.use("fmt") + ".Sprintf(\"Cmd(0x%X)\", int32(c))\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// Kind returns the kind of the command, CmdKindUnknown if it is not declared\n")
+	buf.WriteString("func (c Cmd) Kind() CmdKind {\n")
+	buf.WriteString("\treturn cmdKinds[c]\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// Valid tells whether the command is declared\n")
+	buf.WriteString("func (c Cmd) Valid() bool {\n")
+	buf.WriteString("\t_, ok := cmdNames[c]\n")
+	buf.WriteString("\treturn ok\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// ParseCmd returns the command of the given name, e.g. LoginRequest\n")
+	buf.WriteString("func ParseCmd(name string) (Cmd, error) {\n")
+	buf.WriteString("\tif c, ok := cmdValues[name]; ok {\n")
+	buf.WriteString("\t\treturn c, nil\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn 0, " + imports.use("fmt") + ".Errorf(\"unknown cmd %q\", name)\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// AllCmds returns all the commands sorted by id\n")
+	buf.WriteString("func AllCmds() []Cmd {\n")
+	buf.WriteString("\treturn append([]Cmd(nil), allCmds...)\n")
+	buf.WriteString("}\n")
+}
+
+// cmdIdentifiers are the identifiers starting with Cmd written by the go
+// targets, which the typed constants Cmd<message> must not take
+var cmdIdentifiers = []string{
+	"CmdKind", "CmdKindUnknown", "CmdKindCustom", "CmdKindRequest", "CmdKindResponse", "CmdKindEvent",
+	"CmdName", "CmdMessage", "CmdOf", "CmdTypeError", "CmdMarshaler",
+}
+
+// checkCmdConsts fails for each typed constant Cmd<message> colliding with a
+// generated identifier or with a message or enum of the go package
+func (g *Generator) checkCmdConsts(file *protogen.File, msgs []*protogen.Message) {
+	taken := g.goPackageIdents(file)
+	for _, name := range cmdIdentifiers {
+		taken[name] = "a generated identifier"
+	}
+	for _, msg := range msgs {
+		name := "Cmd" + msg.GoIdent.GoName
+		if other, ok := taken[name]; ok {
+			g.fail("%s: the constant %s of %s collides with %s, rename the message", file.Desc.Path(), name, msg.Desc.FullName(), other)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// targetFrame writes an Encoder and a Decoder of [length][cmd][body] frames,
+// the length counting the cmd and the body. The layout is set by the params
+// frame_len=2|4 and frame_cmd=2|4 (widths in bytes, 4 by default),
+// frame_endian=big|little (big by default) and frame_max, the largest length
+// accepted (1048576 by default, at most what the length width holds). The
+// generated code uses the unpack and pack targets, which must be enabled too.
+const targetFrame = "frame"
+
+const (
+	optionFrameLen    = "frame_len"
+	optionFrameCmd    = "frame_cmd"
+	optionFrameEndian = "frame_endian"
+	optionFrameMax    = "frame_max"
+)
+
+func init() {
+	RegisterTarget(&fileTarget{name: targetFrame, perPackage: true, generate: (*Generator).generateFrameFile})
+	RegisterOption(optionFrameLen)
+	RegisterOption(optionFrameCmd)
+	RegisterOption(optionFrameEndian)
+	RegisterOption(optionFrameMax)
+}
+
+// frameLayout is the header layout of the frames
+type frameLayout struct {
+	lenSize int
+	cmdSize int
+	order   string // BigEndian or LittleEndian of encoding/binary
+	max     uint64
+}
+
+// frameLayout reads the frame params
+func (g *Generator) frameLayout() frameLayout {
+	for _, name := range []string{targetPackMsg, targetUnpack} {
+		if _, ok := g.Params[name]; !ok {
+			g.fail("%s: the generated code needs the %s target, add %s to the params", targetFrame, name, name)
+		}
+	}
+	layout := frameLayout{lenSize: 4, cmdSize: 4, order: "BigEndian", max: 1 << 20}
+	for _, width := range []struct {
+		name string
+		size *int
+	}{{optionFrameLen, &layout.lenSize}, {optionFrameCmd, &layout.cmdSize}} {
+		switch value := g.Params[width.name]; value {
+		case "":
+		case "2", "4":
+			*width.size, _ = strconv.Atoi(value)
+		default:
+			g.fail("%s: unsupported width %q, expected 2 or 4", width.name, value)
+		}
+	}
+	switch value := g.Params[optionFrameEndian]; value {
+	case "", "big":
+	case "little":
+		layout.order = "LittleEndian"
+	default:
+		g.fail("%s: unknown value %q, expected big or little", optionFrameEndian, value)
+	}
+
+	// the limit also keeps MaxFrameSize an int on 32 bit platforms
+	limit := uint64(1)<<(8*uint(layout.lenSize)) - 1
+	if limit > math.MaxInt32 {
+		limit = math.MaxInt32
+	}
+	if value := g.Params[optionFrameMax]; value != "" {
+		max, err := strconv.ParseUint(value, 0, 64)
+		if err != nil || max < uint64(layout.cmdSize) || max > limit {
+			g.fail("%s: invalid size %q, expected a number between %d and %d", optionFrameMax, value, layout.cmdSize, limit)
+		}
+		layout.max = max
+	}
+	if layout.max > limit {
+		layout.max = limit
+	}
+	return layout
+}
+
+func (g *Generator) generateFrameFile(file *protogen.File) *pluginpb.CodeGeneratorResponse_File {
+	buf := new(bytes.Buffer)
+	imports := g.newGoImports(file)
+	layout := g.frameLayout()
+	if layout.cmdSize == 2 {
+		// the frame is written once per package, for the commands of all the
+		// files of the go package
+		for _, f := range g.goPackageFiles(file) {
+			ids := g.getCmdIds(f)
+			for _, msg := range g.cmdMessages(f) {
+				if id := ids[msg.Desc.FullName()]; id > 0xFFFF {
+					g.fail("%s: the cmd 0x%x of %s does not fit in %s=2", f.Desc.Path(), id, msg.Desc.FullName(), optionFrameCmd)
+				}
+			}
+		}
+	}
+	binaryPkg, ioPkg := imports.use("encoding/binary"), imports.use("io")
+	order := binaryPkg + "." + layout.order
+	uintLen, uintCmd := "Uint"+strconv.Itoa(8*layout.lenSize), "Uint"+strconv.Itoa(8*layout.cmdSize)
+
+	endian := "big"
+	if layout.order == "LittleEndian" {
+		endian = "little"
+	}
+	buf.WriteString("// Frames are laid out as [length][cmd][body], the length counting the cmd and\n")
+	buf.WriteString("// the body. The length takes " + strconv.Itoa(layout.lenSize) + " bytes, the cmd " + strconv.Itoa(layout.cmdSize) + " bytes, both " + endian + " endian.\n")
+	buf.WriteString("const (\n")
+	buf.WriteString("\tframeLenSize = " + strconv.Itoa(layout.lenSize) + "\n")
+	buf.WriteString("\tframeCmdSize = " + strconv.Itoa(layout.cmdSize) + "\n")
+	buf.WriteString("\t// MaxFrameSize is the largest length of a frame\n")
+	buf.WriteString("\tMaxFrameSize = " + strconv.FormatUint(layout.max, 10) + "\n")
+	buf.WriteString(")\n\n")
+
+	errorsPkg := imports.use("errors")
+	buf.WriteString("var (\n")
+	buf.WriteString("\t// ErrFrameTooLarge is returned for a frame longer than MaxFrameSize\n")
+	buf.WriteString("\tErrFrameTooLarge = " + errorsPkg + ".New(\"frame too large\")\n")
+	buf.WriteString("\t// ErrFrameTooShort is returned for a frame too short to hold a cmd\n")
+	buf.WriteString("\tErrFrameTooShort = " + errorsPkg + ".New(\"frame too short\")\n")
+	buf.WriteString("\t// ErrCmdOutOfRange is returned for a cmd that does not fit in the frame header\n")
+	buf.WriteString("\tErrCmdOutOfRange = " + errorsPkg + ".New(\"cmd out of range\")\n")
+	buf.WriteString(")\n\n")
+
+	buf.WriteString("// CmdMarshaler is implemented by the command messages\n")
+	buf.WriteString("type CmdMarshaler interface {\n")
+	buf.WriteString("\tMarshalCmd() (int32, []byte, error)\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// Encoder writes frames to an io.Writer\n")
+	buf.WriteString("type Encoder struct {\n")
+	buf.WriteString("\tw   " + ioPkg + ".Writer\n")
+	buf.WriteString("\tbuf []byte\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// NewEncoder returns an Encoder writing to w\n")
+	buf.WriteString("func NewEncoder(w " + ioPkg + ".Writer) *Encoder {\n")
+	buf.WriteString("\treturn &Encoder{w: w}\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// Encode writes a frame holding the cmd and the body with a single Write\n")
+	buf.WriteString("func (e *Encoder) Encode(cmd int32, body []byte) error {\n")
+	if layout.cmdSize == 2 {
+		buf.WriteString("\tif cmd < 0 || cmd > 0xFFFF {\n")
+	} else {
+		buf.WriteString("\tif cmd < 0 {\n")
+	}
+	buf.WriteString("\t\treturn ErrCmdOutOfRange\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif len(body) > MaxFrameSize-frameCmdSize {\n")
+	buf.WriteString("\t\treturn ErrFrameTooLarge\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tvar header [frameLenSize + frameCmdSize]byte\n")
+	buf.WriteString("\t" + order + ".Put" + uintLen + "(header[:frameLenSize], " + lowerFirst(uintLen) + "(frameCmdSize+len(body)))\n")
+	buf.WriteString("\t" + order + ".Put" + uintCmd + "(header[frameLenSize:], " + lowerFirst(uintCmd) + "(cmd))\n")
+	buf.WriteString("\te.buf = append(append(e.buf[:0], header[:]...), body...)\n")
+	buf.WriteString("\t_, err := e.w.Write(e.buf)\n")
+	buf.WriteString("\treturn err\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// EncodeMessage writes a frame holding the command message\n")
+	buf.WriteString("func (e *Encoder) EncodeMessage(m CmdMarshaler) error {\n")
+	buf.WriteString("\tcmd, body, err := m.MarshalCmd()\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn e.Encode(cmd, body)\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// Decoder reads frames from an io.Reader\n")
+	buf.WriteString("type Decoder struct {\n")
+	buf.WriteString("\tr      " + ioPkg + ".Reader\n")
+	buf.WriteString("\theader [frameLenSize]byte\n")
+	buf.WriteString("\tbuf    []byte\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// NewDecoder returns a Decoder reading from r\n")
+	buf.WriteString("func NewDecoder(r " + ioPkg + ".Reader) *Decoder {\n")
+	buf.WriteString("\treturn &Decoder{r: r}\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// Decode reads the next frame. The body is only valid until the next call. It\n")
+	buf.WriteString("// returns io.EOF when r ends between two frames, io.ErrUnexpectedEOF when it\n")
+	buf.WriteString("// ends inside a frame.\n")
+	buf.WriteString("func (d *Decoder) Decode() (int32, []byte, error) {\n")
+	buf.WriteString("\tif _, err := " + ioPkg + ".ReadFull(d.r, d.header[:]); err != nil {\n")
+	buf.WriteString("\t\treturn 0, nil, err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tsize := " + order + "." + uintLen + "(d.header[:])\n")
+	buf.WriteString("\tif size > MaxFrameSize {\n")
+	buf.WriteString("\t\treturn 0, nil, ErrFrameTooLarge\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif size < frameCmdSize {\n")
+	buf.WriteString("\t\treturn 0, nil, ErrFrameTooShort\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif cap(d.buf) < int(size) {\n")
+	buf.WriteString("\t\td.buf = make([]byte, size)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\td.buf = d.buf[:size]\n")
+	buf.WriteString("\tif _, err := " + ioPkg + ".ReadFull(d.r, d.buf); err != nil {\n")
+	buf.WriteString("\t\tif err == " + ioPkg + ".EOF {\n")
+	buf.WriteString("\t\t\terr = " + ioPkg + ".ErrUnexpectedEOF\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t\treturn 0, nil, err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tcmd := int32(" + order + "." + uintCmd + "(d.buf[:frameCmdSize]))\n")
+	buf.WriteString("\treturn cmd, d.buf[frameCmdSize:], nil\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// DecodeMessage reads the next frame and decodes its body with Unpack\n")
+	buf.WriteString("func (d *Decoder) DecodeMessage() (Message, error) {\n")
+	buf.WriteString("\tcmd, body, err := d.Decode()\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn nil, err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn Unpack(cmd, body)\n")
+	buf.WriteString("}\n")
+
+	content := new(bytes.Buffer)
+	g.generateGoFileHeader(content, file)
+	imports.write(content)
+	content.Write(buf.Bytes())
+
+	response := new(pluginpb.CodeGeneratorResponse_File)
+	generatedFileName := g.filename(file) + ".frame.go"
+	fileContent := content.String()
+	response.Name = &generatedFileName
+	response.Content = &fileContent
+	return response
+}
+
+// lowerFirst returns the name with its first letter in lower case, Uint32
+// gives the go type uint32
+func lowerFirst(name string) string {
+	return string(name[0]|0x20) + name[1:]
+}
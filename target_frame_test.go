@@ -0,0 +1,128 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestFrameLayout(t *testing.T) {
+	tests := []struct {
+		params string
+		want   frameLayout
+		err    string
+	}{{
+		params: "frame",
+		want:   frameLayout{lenSize: 4, cmdSize: 4, order: "BigEndian", max: 1 << 20},
+	}, {
+		params: "frame,frame_len=2,frame_cmd=2,frame_endian=little",
+		want:   frameLayout{lenSize: 2, cmdSize: 2, order: "LittleEndian", max: 0xFFFF},
+	}, {
+		params: "frame,frame_len=2,frame_max=0x100",
+		want:   frameLayout{lenSize: 2, cmdSize: 4, order: "BigEndian", max: 0x100},
+	}, {
+		params: "frame,frame_max=4294967295",
+		err:    `frame_max: invalid size "4294967295", expected a number between 4 and 2147483647`,
+	}, {
+		params: "frame,frame_len=2,frame_max=65536",
+		err:    `frame_max: invalid size "65536", expected a number between 4 and 65535`,
+	}, {
+		params: "frame,frame_cmd=2,frame_max=1",
+		err:    `frame_max: invalid size "1", expected a number between 2 and`,
+	}, {
+		params: "frame,frame_max=-1",
+		err:    `frame_max: invalid size "-1"`,
+	}, {
+		params: "frame,frame_len=3",
+		err:    `frame_len: unsupported width "3", expected 2 or 4`,
+	}, {
+		params: "frame,frame_cmd=8",
+		err:    `frame_cmd: unsupported width "8", expected 2 or 4`,
+	}, {
+		params: "frame,frame_endian=middle",
+		err:    `frame_endian: unknown value "middle", expected big or little`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.params, func(t *testing.T) {
+			g := NewGenerator()
+			newTestFile(t, g, "pack,unpack,"+tt.params, nil)
+			layout := g.frameLayout()
+			if tt.err != "" {
+				if !g.Failed() || !strings.Contains(strings.Join(g.errors, "\n"), tt.err) {
+					t.Fatalf("errors %q, want %q", g.errors, tt.err)
+				}
+				return
+			}
+			if g.Failed() {
+				t.Fatalf("unexpected errors %q", g.errors)
+			}
+			if layout != tt.want {
+				t.Fatalf("layout %+v, want %+v", layout, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrameLayoutNeedsPackAndUnpack(t *testing.T) {
+	g := NewGenerator()
+	newTestFile(t, g, "unpack,frame", nil)
+	g.frameLayout()
+	if want := "frame: the generated code needs the pack target"; !strings.Contains(strings.Join(g.errors, "\n"), want) {
+		t.Fatalf("errors %q, want %q", g.errors, want)
+	}
+}
+
+func TestFrameCmdWidth(t *testing.T) {
+	g := NewGenerator()
+	file := newTestFile(t, g, "pack,unpack,frame,frame_cmd=2", []testMessage{{name: "LoginRequest", id: pin(0x10000)}})
+	g.generateFrameFile(file)
+	if want := "the cmd 0x10000 of game.LoginRequest does not fit in frame_cmd=2"; !strings.Contains(strings.Join(g.errors, "\n"), want) {
+		t.Fatalf("errors %q, want %q", g.errors, want)
+	}
+}
+
+// frameStub declares what the frame file takes from the unpack target
+const frameStub = `package game
+
+type Message interface{}
+
+func Unpack(cmd int32, data []byte) (Message, error) { return nil, nil }
+`
+
+// TestFrameCompiles type checks the generated codec for each header layout
+func TestFrameCompiles(t *testing.T) {
+	for _, params := range []string{
+		"frame",
+		"frame,frame_len=2,frame_cmd=2",
+		"frame,frame_len=2,frame_endian=little",
+		"frame,frame_cmd=2,frame_endian=little,frame_max=64",
+	} {
+		t.Run(params, func(t *testing.T) {
+			g := NewGenerator()
+			file := newTestFile(t, g, "pack,unpack,"+params, []testMessage{{name: "LoginRequest"}})
+			f := g.generateFrameFile(file)
+			g.formatGo(f)
+			if g.Failed() {
+				t.Fatalf("unexpected errors %q", g.errors)
+			}
+
+			fset := token.NewFileSet()
+			var files []*ast.File
+			for name, src := range map[string]string{f.GetName(): f.GetContent(), "stub.go": frameStub} {
+				parsed, err := parser.ParseFile(fset, name, src, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				files = append(files, parsed)
+			}
+			conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+			if _, err := conf.Check("example.com/game", fset, files, nil); err != nil {
+				t.Fatalf("%v\n%s", err, f.GetContent())
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const targetJava = "java"
+
+func init() {
+	RegisterTarget(&fileTarget{name: targetJava, perPackage: true, generate: (*Generator).generateJavaFile})
+	RegisterOption("pkg")
+}
+
+func (g *Generator) generateJavaFile(file *protogen.File) *pluginpb.CodeGeneratorResponse_File {
+	buf := new(bytes.Buffer)
+	pkg, hasPkg := g.Params["pkg"]
+	if !hasPkg {
+		pkg = string(file.Desc.Package())
+	}
+
+	tab := "    " // 4 spaces for tab by default
+	if _, ok := g.Params["usetabs"]; ok {
+		tab = "\t"
+	}
+
+	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
+	buf.WriteString("// source: ")
+	buf.WriteString(file.Desc.Path())
+	buf.WriteByte('\n')
+	buf.WriteString("// DO NOT EDIT!\n")
+	buf.WriteByte('\n')
+	buf.WriteString("package ")
+	buf.WriteString(pkg)
+	buf.WriteString(";\n\n")
+	buf.WriteString("import java.util.Map;\n")
+	buf.WriteString("import java.util.HashMap;\n\n")
+	buf.WriteString("public class MessageTypes {\n")
+	cmdIds := g.getCmdIds(file)
+	for _, msg := range g.allMessages(file) {
+		if !g.isCmdType(msg) {
+			continue
+		}
+		writeBlockDoc(buf, tab, commentLines(msg.Comments))
+		buf.WriteString(tab)
+		buf.WriteString("public static final int ")
+		buf.WriteString(strings.Title(flatName(msg.Desc)))
+		buf.WriteString(fmt.Sprintf(" = 0x%X;\n", cmdIds[msg.Desc.FullName()]))
+	}
+
+	buf.WriteString("\n")
+	buf.WriteString(tab)
+	buf.WriteString("private static Map<Integer, String> messageTypeToMessageNameMapping = new HashMap<Integer, String>();\n")
+	buf.WriteString("private static Map<String, Integer> messageNameToMessageTypeMapping = new HashMap<String, Integer>();\n")
+	buf.WriteString(tab)
+	buf.WriteString("private static Map<Integer, Integer> requestToResponseMapping = new HashMap<Integer, Integer>();\n")
+	buf.WriteString(tab)
+	buf.WriteString("private static Map<Integer, Integer> responseToRequestMapping = new HashMap<Integer, Integer>();\n\n")
+	buf.WriteString(tab)
+	buf.WriteString("static {\n")
+	for _, msg := range g.allMessages(file) {
+		if !g.isCmdType(msg) {
+			continue
+		}
+		buf.WriteString(tab)
+		buf.WriteString(tab)
+		buf.WriteString("messageTypeToMessageNameMapping.put(")
+		buf.WriteString(strings.Title(flatName(msg.Desc)))
+		buf.WriteString(", \"")
+		buf.WriteString(localName(msg.Desc))
+		buf.WriteString("\");\n")
+		buf.WriteString(tab)
+		buf.WriteString(tab)
+		buf.WriteString("messageNameToMessageTypeMapping.put(\"")
+		buf.WriteString(localName(msg.Desc))
+		buf.WriteString("\", ")
+		buf.WriteString(strings.Title(flatName(msg.Desc)))
+		buf.WriteString(");\n")
+	}
+	for _, pair := range g.cmdPairs(file) {
+		request, response := strings.Title(flatName(pair.request.Desc)), strings.Title(flatName(pair.response.Desc))
+		buf.WriteString(tab)
+		buf.WriteString(tab)
+		buf.WriteString("requestToResponseMapping.put(" + request + ", " + response + ");\n")
+		if pair.reverse {
+			buf.WriteString(tab)
+			buf.WriteString(tab)
+			buf.WriteString("responseToRequestMapping.put(" + response + ", " + request + ");\n")
+		}
+	}
+	buf.WriteString(tab)
+	buf.WriteString("}\n\n")
+	buf.WriteString(tab)
+	buf.WriteString("public static String getMessageTypeName(int messageTypeId) {\n")
+	buf.WriteString(tab)
+	buf.WriteString(tab)
+	buf.WriteString("return messageTypeToMessageNameMapping.get(messageTypeId);\n")
+	buf.WriteString(tab)
+	buf.WriteString("}\n\n")
+	buf.WriteString(tab)
+	buf.WriteString("public static Integer getMessageTypeId(String messageTypeName) {\n")
+	buf.WriteString(tab)
+	buf.WriteString(tab)
+	buf.WriteString("return messageNameToMessageTypeMapping.get(messageTypeName);\n")
+	buf.WriteString(tab)
+	buf.WriteString("}\n\n")
+	buf.WriteString(tab)
+	buf.WriteString("public static Integer getResponseTypeId(int requestTypeId) {\n")
+	buf.WriteString(tab)
+	buf.WriteString(tab)
+	buf.WriteString("return requestToResponseMapping.get(requestTypeId);\n")
+	buf.WriteString(tab)
+	buf.WriteString("}\n\n")
+	buf.WriteString(tab)
+	buf.WriteString("public static Integer getRequestTypeId(int responseTypeId) {\n")
+	buf.WriteString(tab)
+	buf.WriteString(tab)
+	buf.WriteString("return responseToRequestMapping.get(responseTypeId);\n")
+	buf.WriteString(tab)
+	buf.WriteString("}\n")
+	buf.WriteString("}\n")
+	response := new(pluginpb.CodeGeneratorResponse_File)
+	generatedFileName := "MessageTypes.java"
+	fileContent := buf.String()
+	response.Name = &generatedFileName
+	response.Content = &fileContent
+	return response
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const targetPackMsg = "pack"
+
+func init() {
+	RegisterTarget(&fileTarget{name: targetPackMsg, generate: (*Generator).generatePackFile})
+}
+
+func (g *Generator) generatePackFile(file *protogen.File) *pluginpb.CodeGeneratorResponse_File {
+	buf := new(bytes.Buffer)
+	isFirstMsg := true
+	imports := g.newGoImports(file)
+
+	for _, msg := range g.allMessages(file) {
+		if !isFirstMsg {
+			buf.WriteByte('\n')
+		}
+
+		isFirstArgument := true
+		assignmentBuf := new(bytes.Buffer)
+		msgTypeName := msg.GoIdent.GoName
+
+		writeGoDoc(buf, "", commentLines(msg.Comments))
+		buf.WriteString("func New")
+
+		buf.WriteString(msgTypeName)
+		buf.WriteByte('(')
+		for _, field := range msg.Fields {
+			if field.Desc.Kind() == protoreflect.GroupKind {
+				g.fail("%s: message %s field %s: unsupported type %s", file.Desc.Path(), msg.Desc.FullName(), field.Desc.Name(), field.Desc.Kind())
+				continue
+			}
+			// a oneof takes a single argument of the interface implemented by the
+			// wrappers of its fields, e.g. &Msg_Name{Name: "x"}
+			oneof := field.Oneof
+			if oneof != nil && oneof.Desc.IsSynthetic() {
+				oneof = nil
+			}
+			if oneof != nil && field != oneof.Fields[0] {
+				continue
+			}
+			if !isFirstArgument {
+				buf.WriteString(", ")
+			}
+
+			if oneof != nil {
+				argumentName := strings.ToUpper(string(oneof.Desc.Name()))
+				buf.WriteString(argumentName)
+				buf.WriteString(" is")
+				buf.WriteString(oneof.GoIdent.GoName)
+
+				assignmentBuf.WriteByte('\t')
+				assignmentBuf.WriteByte('\t')
+				assignmentBuf.WriteString(oneof.GoName)
+				assignmentBuf.WriteString(": ")
+				assignmentBuf.WriteString(argumentName)
+				assignmentBuf.WriteString(",\n")
+				isFirstArgument = false
+				continue
+			}
+
+			argumentName := strings.ToUpper(string(field.Desc.Name())) //strings.Title(field.GetName())
+			attributeName := field.GoName
+			buf.WriteString(argumentName)
+			buf.WriteByte(' ')
+			_, builtinType := g.typesMapping[field.Desc.Kind()]
+			repeatedField := field.Desc.IsList()
+			isEnumType := field.Enum != nil
+			typeName := g.goElemType(field, imports)
+			if field.Desc.IsMap() {
+				typeName = "map[" + g.goElemType(field.Message.Fields[0], imports) + "]" + g.goElemType(field.Message.Fields[1], imports)
+			}
+			wktType, wktValue, isWellKnown := g.goWellKnownArgument(field, argumentName, imports)
+			if isWellKnown {
+				typeName = wktType
+			}
+
+			if repeatedField {
+				typeName = "[]" + typeName
+			}
+
+			buf.WriteString(typeName)
+
+			assignmentBuf.WriteByte('\t')
+			assignmentBuf.WriteByte('\t')
+			assignmentBuf.WriteString(attributeName)
+			assignmentBuf.WriteString(": ")
+			if isWellKnown {
+				assignmentBuf.WriteString(wktValue)
+				assignmentBuf.WriteString(",\n")
+			} else if builtinType && !strings.HasPrefix(typeName, "[]") {
+				if !field.Desc.HasPresence() {
+					assignmentBuf.WriteString(argumentName)
+					assignmentBuf.WriteString(",\n")
+
+				} else {
+					assignmentBuf.WriteByte('&')
+					assignmentBuf.WriteString(argumentName)
+					assignmentBuf.WriteString(",\n")
+				}
+			} else {
+				if isEnumType && !repeatedField && field.Desc.HasPresence() {
+					assignmentBuf.WriteString("&")
+				}
+				assignmentBuf.WriteString(argumentName)
+				assignmentBuf.WriteString(",\n")
+			}
+
+			if isFirstArgument {
+				isFirstArgument = false
+			}
+		}
+		buf.WriteString(") *")
+		buf.WriteString(msgTypeName)
+		buf.WriteString(" {\n")
+
+		buf.WriteByte('\t')
+		buf.WriteString("return &")
+		buf.WriteString(msgTypeName)
+		if assignmentBuf.Len() > 0 {
+			buf.WriteString("{\n")
+			buf.WriteString(assignmentBuf.String())
+			buf.WriteByte('\t')
+			buf.WriteString("}\n")
+		} else {
+			buf.WriteString("{}\n")
+		}
+		buf.WriteString("}\n")
+
+		// generate marshal code
+		buf.WriteString("func (m *")
+		buf.WriteString(msgTypeName)
+		buf.WriteString(") Bytes() []byte {\n")
+		buf.WriteByte('\t')
+		buf.WriteString("data, err := " + g.goMarshal("m", imports) + "\n")
+		buf.WriteByte('\t')
+		buf.WriteString("if err != nil { panic(err) }\n")
+		buf.WriteByte('\t')
+		buf.WriteString("return data\n")
+		buf.WriteString("}\n")
+
+		if g.isCmdType(msg) {
+			buf.WriteString("\n// MarshalCmd returns the command of the message with its encoding\n")
+			buf.WriteString("func (m *")
+			buf.WriteString(msgTypeName)
+			buf.WriteString(") MarshalCmd() (int32, []byte, error) {\n")
+			buf.WriteString("\tdata, err := " + g.goMarshal("m", imports) + "\n")
+			buf.WriteString("\treturn Cmd_" + msgTypeName + ", data, err\n")
+			buf.WriteString("}\n")
+		}
+
+		if isFirstMsg {
+			isFirstMsg = false
+		}
+	}
+
+	content := new(bytes.Buffer)
+	g.generateGoFileHeader(content, file)
+	imports.write(content)
+	content.Write(buf.Bytes())
+
+	response := new(pluginpb.CodeGeneratorResponse_File)
+	generatedFileName := g.filename(file) + ".pack.go"
+	fileContent := content.String()
+	response.Name = &generatedFileName
+	response.Content = &fileContent
+	return response
+}
+
+// goElemType returns the go type of a single value of the field, the element
+// type for repeated fields
+func (g *Generator) goElemType(field *protogen.Field, imports *goImports) string {
+	switch {
+	case field.Enum != nil:
+		return imports.qualify(field.Enum.GoIdent)
+	case field.Message != nil:
+		return "*" + imports.qualify(field.Message.GoIdent)
+	default:
+		return g.typesMapping[field.Desc.Kind()]
+	}
+}
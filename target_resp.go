@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const targetGoModelResp = "go.resp"
+
+func init() {
+	RegisterTarget(&fileTarget{name: targetGoModelResp, generate: (*Generator).generateGoRespModelFile})
+}
+
+func (g *Generator) generateGoRespModelFile(file *protogen.File) *pluginpb.CodeGeneratorResponse_File {
+	buf := new(bytes.Buffer)
+	imports := g.newGoImports(file)
+
+	// ResponseMessage and CODE may be declared in an imported proto file
+	respType, codeType, codeSuccess := "ResponseMessage", "CODE", "CODE_SUCCESS"
+	respProto3 := g.isProto3(file)
+	if msg := g.findMessage(file, "ResponseMessage"); msg != nil {
+		respType = imports.qualify(msg.GoIdent)
+		respProto3 = msg.Desc.ParentFile().Syntax() == protoreflect.Proto3
+	}
+	if enum := g.findEnum(file, "CODE"); enum != nil {
+		codeType = imports.qualify(enum.GoIdent)
+		codeSuccess = imports.qualify(protogen.GoIdent{GoName: "CODE_SUCCESS", GoImportPath: enum.GoIdent.GoImportPath})
+	}
+	buf.WriteString("var msgPool = &" + imports.use("sync") + ".Pool{New: func() interface{} { return new(" + respType + ") }}\n\n")
+
+	var FuncStr = func(MsgTypeName string, withCode, withBody bool) string {
+		okBuf := new(bytes.Buffer)
+		okBuf.WriteString("\tresp := msgPool.Get().(*" + respType + ")\n")
+		if respProto3 {
+			okBuf.WriteString("\tresp.MessageType = Cmd_" + MsgTypeName)
+
+		} else {
+			okBuf.WriteString("\tresp.MessageType = " + g.goProtoHelpers(imports) + ".Int32(Cmd_" + MsgTypeName + ")")
+		}
+
+		okBuf.WriteByte('\n')
+		okBuf.WriteString("\tresp.ErrorCode = ")
+		if withCode {
+			okBuf.WriteString(codeSuccess)
+		} else {
+			okBuf.WriteString("errCode")
+		}
+		if !respProto3 {
+			okBuf.WriteString(".Enum()")
+		}
+
+		okBuf.WriteByte('\n')
+		okBuf.WriteString("\tresp.Body = ")
+		if withBody {
+			okBuf.WriteString("msg.Bytes()")
+		} else {
+			okBuf.WriteString("nil")
+		}
+		okBuf.WriteByte('\n')
+		okBuf.WriteString("\tret := resp.Bytes()")
+		okBuf.WriteByte('\n')
+		okBuf.WriteString("\tmsgPool.Put(resp)")
+		okBuf.WriteByte('\n')
+		okBuf.WriteString("\treturn ret")
+		okBuf.WriteByte('\n')
+		return okBuf.String()
+	}
+
+	for _, msg := range g.allMessages(file) {
+
+		msgTypeName := msg.GoIdent.GoName
+		if g.isCmdType(msg) {
+
+			//error message
+			doc := []string{"Reply" + msgTypeName + "Err replies to " + msgTypeName + " with the error code"}
+			if lines := commentLines(msg.Comments); len(lines) > 0 {
+				doc = append(append(doc, ""), lines...)
+			}
+			writeGoDoc(buf, "", doc)
+			buf.WriteString("func Reply")
+			buf.WriteString(msgTypeName)
+			buf.WriteString("Err(errCode " + codeType + ") []byte {\n")
+			buf.WriteString(FuncStr(msgTypeName, false, false))
+			buf.WriteByte('}')
+			buf.WriteByte('\n')
+			buf.WriteByte('\n')
+
+			//ok message but empty
+			writeGoDoc(buf, "", []string{"Reply" + msgTypeName + "Ok replies to " + msgTypeName + " with success and no body"})
+			buf.WriteString("func Reply")
+			buf.WriteString(msgTypeName)
+			buf.WriteString("Ok() []byte {\n")
+			buf.WriteString(FuncStr(msgTypeName, true, false))
+			buf.WriteByte('}')
+			buf.WriteByte('\n')
+			buf.WriteByte('\n')
+
+			//ok message with body
+			writeGoDoc(buf, "", []string{"Reply" + msgTypeName + "OkWith replies to " + msgTypeName + " with success and the message as body"})
+			buf.WriteString("func Reply")
+			buf.WriteString(msgTypeName)
+			buf.WriteString("OkWith(msg *")
+			buf.WriteString(msgTypeName)
+			buf.WriteString(") []byte {\n")
+			buf.WriteString(FuncStr(msgTypeName, true, true))
+			buf.WriteByte('}')
+			buf.WriteByte('\n')
+			buf.WriteByte('\n')
+		}
+
+	}
+
+	content := new(bytes.Buffer)
+	g.generateGoFileHeader(content, file)
+	imports.write(content)
+	content.Write(buf.Bytes())
+
+	response := new(pluginpb.CodeGeneratorResponse_File)
+	generatedFileName := g.filename(file) + ".resp.go"
+	fileContent := content.String()
+	response.Name = &generatedFileName
+	response.Content = &fileContent
+	return response
+}
+
+// findMessage looks up a top level message by name in the file, then in the
+// files it imports
+func (g *Generator) findMessage(file *protogen.File, name protoreflect.Name) *protogen.Message {
+	for _, f := range g.withImports(file) {
+		for _, msg := range f.Messages {
+			if msg.Desc.Name() == name {
+				return msg
+			}
+		}
+	}
+	return nil
+}
+
+// findEnum looks up a top level enum by name in the file, then in the files it
+// imports
+func (g *Generator) findEnum(file *protogen.File, name protoreflect.Name) *protogen.Enum {
+	for _, f := range g.withImports(file) {
+		for _, enum := range f.Enums {
+			if enum.Desc.Name() == name {
+				return enum
+			}
+		}
+	}
+	return nil
+}
+
+// withImports returns the file followed by the files it imports directly
+func (g *Generator) withImports(file *protogen.File) []*protogen.File {
+	files := []*protogen.File{file}
+	imports := file.Desc.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		if f, ok := g.Plugin.FilesByPath[imports.Get(i).Path()]; ok {
+			files = append(files, f)
+		}
+	}
+	return files
+}
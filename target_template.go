@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// targetTemplate renders the user templates given by template=path/to/x.tmpl,
+// the param may be repeated. Each template is executed once per proto file
+// with a *TemplateFile as data, and the output is written next to the proto
+// file, named after the template: game.proto with cmd.lua.tmpl gives
+// game.cmd.lua.
+const targetTemplate = "template"
+
+func init() {
+	RegisterTarget(&templateTarget{})
+}
+
+// TemplateFile is the data model of a proto file given to the user templates
+type TemplateFile struct {
+	Name      string // path of the proto file, e.g. game/game.proto
+	Package   string // proto package
+	GoPackage string // go package name
+	AppId     int    // value of App.Id, 1 if not declared
+	Commands  []*TemplateCommand
+	Messages  []*TemplateMessage
+	Enums     []*TemplateEnum
+	Params    map[string]string // the plugin params
+}
+
+// TemplateCommand is a command message with its assigned id
+type TemplateCommand struct {
+	Name     string // message name relative to the package, e.g. LoginRequest
+	FullName string // proto full name, e.g. game.LoginRequest
+	Id       int
+	Kind     string // request, response, event or custom
+	Message  *TemplateMessage
+	Pair     *TemplateCommand // the response of a request, the request of a response
+}
+
+// TemplateMessage is a message declared in the proto file
+type TemplateMessage struct {
+	Name      string // name relative to the package, e.g. Outer.Inner
+	FullName  string
+	GoName    string // name of the go type generated by protoc-gen-go
+	IsCommand bool
+	Id        int      // command id, 0 when the message is not a command
+	Kind      string   // command kind, empty when the message is not a command
+	Comments  []string // lines of the leading and trailing comments
+	Fields    []*TemplateField
+}
+
+// TemplateField is a field of a message
+type TemplateField struct {
+	Name     string
+	GoName   string
+	Number   int
+	Comments []string
+	Kind     string // proto kind, e.g. int32, string, message, enum
+	TypeName string // full name of the message or enum type, empty for scalars
+	Repeated bool
+	Optional bool   // the field tracks presence
+	Oneof    string // name of the oneof the field belongs to, if any
+	Map      bool
+	MapKey   *TemplateField // key of a map field
+	MapValue *TemplateField // value of a map field
+
+	WellKnown bool   // the field is a google.protobuf well-known type
+	TsType    string // ts type of a well-known type, e.g. Date
+	JavaType  string // java type of a well-known type, e.g. Integer
+}
+
+// TemplateEnum is an enum declared in the proto file
+type TemplateEnum struct {
+	Name     string // name relative to the package
+	FullName string
+	Comments []string
+	Values   []*TemplateEnumValue
+}
+
+// TemplateEnumValue is a value of an enum
+type TemplateEnumValue struct {
+	Name     string
+	Number   int
+	Comments []string
+}
+
+var templateFuncs = template.FuncMap{
+	"hex":   func(v int) string { return fmt.Sprintf("0x%X", v) },
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"title": strings.Title,
+	"join":  strings.Join,
+}
+
+type templateTarget struct{}
+
+func (t *templateTarget) Name() string {
+	return targetTemplate
+}
+
+func (t *templateTarget) PerPackage() bool {
+	return false
+}
+
+func (t *templateTarget) Generate(g *Generator, file *protogen.File) []*pluginpb.CodeGeneratorResponse_File {
+	data := g.templateData(file)
+	prefix := strings.TrimSuffix(file.Desc.Path(), path.Ext(file.Desc.Path()))
+
+	var files []*pluginpb.CodeGeneratorResponse_File
+	for _, templatePath := range g.templates {
+		if templatePath == "" {
+			g.fail("template param requires a path, e.g. template=lua/cmd.lua.tmpl")
+			continue
+		}
+		tmpl, err := template.New(path.Base(templatePath)).Funcs(templateFuncs).ParseFiles(templatePath)
+		if err != nil {
+			g.fail("template %s: %v", templatePath, err)
+			continue
+		}
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, data); err != nil {
+			g.fail("%s: template %s: %v", file.Desc.Path(), templatePath, err)
+			continue
+		}
+
+		response := new(pluginpb.CodeGeneratorResponse_File)
+		generatedFileName := prefix + "." + strings.TrimSuffix(path.Base(templatePath), ".tmpl")
+		fileContent := buf.String()
+		response.Name = &generatedFileName
+		response.Content = &fileContent
+		files = append(files, response)
+	}
+	return files
+}
+
+// templateData build the data model of the file for the user templates
+func (g *Generator) templateData(file *protogen.File) *TemplateFile {
+	cmdIds := g.getCmdIds(file)
+	data := &TemplateFile{
+		Name:      file.Desc.Path(),
+		Package:   string(file.Desc.Package()),
+		GoPackage: string(file.GoPackageName),
+		AppId:     g.getAppId(file),
+		Params:    g.Params,
+	}
+
+	for _, enum := range g.allEnums(file) {
+		e := &TemplateEnum{Name: localName(enum.Desc), FullName: string(enum.Desc.FullName()), Comments: commentLines(enum.Comments)}
+		for _, v := range enum.Values {
+			e.Values = append(e.Values, &TemplateEnumValue{Name: string(v.Desc.Name()), Number: int(v.Desc.Number()), Comments: commentLines(v.Comments)})
+		}
+		data.Enums = append(data.Enums, e)
+	}
+
+	for _, msg := range g.allMessages(file) {
+		kind, isCommand := g.cmdKind(msg)
+		m := &TemplateMessage{
+			Name:      localName(msg.Desc),
+			FullName:  string(msg.Desc.FullName()),
+			GoName:    msg.GoIdent.GoName,
+			IsCommand: isCommand,
+			Comments:  commentLines(msg.Comments),
+			Id:        cmdIds[msg.Desc.FullName()],
+			Kind:      string(kind),
+		}
+		for _, field := range msg.Fields {
+			m.Fields = append(m.Fields, templateField(field))
+		}
+		data.Messages = append(data.Messages, m)
+		if m.IsCommand {
+			data.Commands = append(data.Commands, &TemplateCommand{Name: m.Name, FullName: m.FullName, Id: m.Id, Kind: m.Kind, Message: m})
+		}
+	}
+
+	commands := make(map[string]*TemplateCommand)
+	for _, c := range data.Commands {
+		commands[c.FullName] = c
+	}
+	for _, pair := range g.cmdPairs(file) {
+		request, response := commands[string(pair.request.Desc.FullName())], commands[string(pair.response.Desc.FullName())]
+		request.Pair = response
+		if pair.reverse {
+			response.Pair = request
+		}
+	}
+	return data
+}
+
+func templateField(field *protogen.Field) *TemplateField {
+	f := &TemplateField{
+		Name:     string(field.Desc.Name()),
+		GoName:   field.GoName,
+		Number:   int(field.Desc.Number()),
+		Comments: commentLines(field.Comments),
+		Kind:     field.Desc.Kind().String(),
+		Repeated: field.Desc.IsList(),
+		Optional: field.Desc.HasPresence(),
+		Map:      field.Desc.IsMap(),
+	}
+	if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
+		f.Oneof = string(field.Oneof.Desc.Name())
+	}
+	if f.Map {
+		f.MapKey = templateField(field.Message.Fields[0])
+		f.MapValue = templateField(field.Message.Fields[1])
+	} else if field.Enum != nil {
+		f.TypeName = string(field.Enum.Desc.FullName())
+	} else if field.Message != nil {
+		f.TypeName = string(field.Message.Desc.FullName())
+	}
+	if wkt := wellKnown(field); wkt != nil && !f.Map {
+		f.WellKnown = true
+		f.TsType = wkt.tsType
+		f.JavaType = wkt.javaType
+	}
+	return f
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const (
+	targetTS      = "ts"
+	targetTSPB    = "ts.pb"
+	targetTSModel = "ts.model"
+)
+
+func init() {
+	RegisterTarget(&fileTarget{name: targetTS, perPackage: true, generate: (*Generator).generateTSFile})
+	RegisterTarget(&fileTarget{name: targetTSPB, perPackage: true, generate: (*Generator).generateTSProtoBuilderFile})
+	RegisterTarget(&fileTarget{name: targetTSModel, perPackage: true, generate: (*Generator).generateTSProtoModelFile})
+}
+
+func (g *Generator) generateTSFile(file *protogen.File) *pluginpb.CodeGeneratorResponse_File {
+	buf := new(bytes.Buffer)
+
+	tab := "    " // 4 spaces for tab by default
+	if _, ok := g.Params["usetabs"]; ok {
+		tab = "\t"
+	}
+
+	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
+	buf.WriteString("// source: ")
+	buf.WriteString(file.Desc.Path())
+	buf.WriteByte('\n')
+	buf.WriteString("// DO NOT EDIT!\n")
+	buf.WriteByte('\n')
+	buf.WriteString("module proto.cmd {\n")
+	cmdIds := g.getCmdIds(file)
+	for _, msg := range g.allMessages(file) {
+		if !g.isCmdType(msg) {
+			continue
+		}
+		writeBlockDoc(buf, tab, commentLines(msg.Comments))
+		buf.WriteString(tab)
+		buf.WriteString("export var ")
+		buf.WriteString(strings.Title(flatName(msg.Desc)))
+		buf.WriteString(fmt.Sprintf(": number = 0x%X;\n", cmdIds[msg.Desc.FullName()]))
+	}
+
+	buf.WriteByte('\n')
+	buf.WriteString(tab)
+	buf.WriteString("var responseCmds: {[req: number]: number} = {};\n")
+	buf.WriteString(tab)
+	buf.WriteString("var requestCmds: {[resp: number]: number} = {};\n")
+	for _, pair := range g.cmdPairs(file) {
+		request, response := strings.Title(flatName(pair.request.Desc)), strings.Title(flatName(pair.response.Desc))
+		buf.WriteString(tab)
+		buf.WriteString("responseCmds[" + request + "] = " + response + ";\n")
+		if pair.reverse {
+			buf.WriteString(tab)
+			buf.WriteString("requestCmds[" + response + "] = " + request + ";\n")
+		}
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(tab)
+	buf.WriteString("export function responseCmdFor(req: number): number | undefined {\n")
+	buf.WriteString(tab)
+	buf.WriteString(tab)
+	buf.WriteString("return responseCmds[req];\n")
+	buf.WriteString(tab)
+	buf.WriteString("}\n\n")
+	buf.WriteString(tab)
+	buf.WriteString("export function requestCmdFor(resp: number): number | undefined {\n")
+	buf.WriteString(tab)
+	buf.WriteString(tab)
+	buf.WriteString("return requestCmds[resp];\n")
+	buf.WriteString(tab)
+	buf.WriteString("}\n")
+	buf.WriteString("}\n")
+	response := new(pluginpb.CodeGeneratorResponse_File)
+	generatedFileName := "proto.cmd.ts"
+	fileContent := buf.String()
+	response.Name = &generatedFileName
+	response.Content = &fileContent
+	return response
+}
+
+func (g *Generator) generateTSProtoBuilderFile(file *protogen.File) *pluginpb.CodeGeneratorResponse_File {
+	buf := new(bytes.Buffer)
+
+	tab := "    " // 4 spaces for tab by default
+	if _, ok := g.Params["usetabs"]; ok {
+		tab = "\t"
+	}
+
+	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
+	buf.WriteString("// source: ")
+	buf.WriteString(file.Desc.Path())
+	buf.WriteByte('\n')
+	buf.WriteString("// DO NOT EDIT!\n")
+	buf.WriteByte('\n')
+	buf.WriteString("module proto {\n")
+
+	filename := g.baseName(file)
+
+	for _, msg := range g.allMessages(file) {
+		if !g.isCmdType(msg) {
+			continue
+		}
+		writeBlockDoc(buf, tab, commentLines(msg.Comments))
+		buf.WriteString(tab)
+		buf.WriteString("export var ")
+		buf.WriteString(strings.ToUpper(filename))
+		buf.WriteString("_")
+		buf.WriteString(flatName(msg.Desc))
+		buf.WriteString(" = { cmd: proto.")
+		buf.WriteString(filename)
+		buf.WriteString(".")
+		buf.WriteString(flatName(msg.Desc))
+		buf.WriteString(", cls: \"proto.builder.")
+		buf.WriteString(flatName(msg.Desc))
+		buf.WriteString("\"")
+		if msg.Desc.Name() == "LoginResponse" {
+			buf.WriteString(", auto_listen: false")
+		}
+		buf.WriteString("};\n")
+	}
+
+	buf.WriteString("}\n")
+	response := new(pluginpb.CodeGeneratorResponse_File)
+	generatedFileName := "proto.builder.ts"
+	fileContent := buf.String()
+	response.Name = &generatedFileName
+	response.Content = &fileContent
+	return response
+}
+
+func (g *Generator) generateTSProtoModelFile(file *protogen.File) *pluginpb.CodeGeneratorResponse_File {
+	buf := new(bytes.Buffer)
+
+	tab := "    " // 4 spaces for tab by default
+	if _, ok := g.Params["usetabs"]; ok {
+		tab = "\t"
+	}
+
+	buf.WriteString("// Code generated by protoc-gen-gocmd.\n")
+	buf.WriteString("// source: ")
+	buf.WriteString(file.Desc.Path())
+	buf.WriteByte('\n')
+	buf.WriteString("// DO NOT EDIT!\n")
+	buf.WriteByte('\n')
+	buf.WriteString("module proto.model {\n")
+
+	for _, enumType := range g.allEnums(file) {
+		writeBlockDoc(buf, tab, commentLines(enumType.Comments))
+		buf.WriteString(tab)
+		buf.WriteString("export enum ")
+		buf.WriteString(flatName(enumType.Desc))
+		buf.WriteString(" {\n")
+		for _, enumElement := range enumType.Values {
+			writeBlockDoc(buf, tab+tab, commentLines(enumElement.Comments))
+			buf.WriteString(tab)
+			buf.WriteString(tab)
+			buf.WriteString(string(enumElement.Desc.Name()))
+			buf.WriteString(" = ")
+			buf.WriteString(strconv.Itoa(int(enumElement.Desc.Number())))
+			buf.WriteString(",")
+			buf.WriteString("\n")
+		}
+		buf.WriteString(tab)
+		buf.WriteString("}\n\n")
+
+	}
+
+	for _, msg := range g.allMessages(file) {
+		if msg.Desc.Name() == "RequestMessage" {
+			continue
+		}
+		if msg.Desc.Name() == "ResponseMessage" {
+			continue
+		}
+		writeBlockDoc(buf, tab, commentLines(msg.Comments))
+		buf.WriteString(tab)
+		buf.WriteString("export class ")
+		buf.WriteString(flatName(msg.Desc))
+		buf.WriteString(" {\n")
+
+		for _, field := range msg.Fields {
+			if field.Desc.Kind() == protoreflect.GroupKind {
+				g.fail("%s: message %s field %s: unsupported type %s", file.Desc.Path(), msg.Desc.FullName(), field.Desc.Name(), field.Desc.Kind())
+				continue
+			}
+			writeBlockDoc(buf, tab+tab, commentLines(field.Comments))
+			buf.WriteString(tab)
+			buf.WriteString(tab)
+			buf.WriteString("public ")
+			buf.WriteString(string(field.Desc.Name()))
+			buf.WriteString(": ")
+
+			tsTypeName := g.tsElemType(field)
+			if field.Desc.IsMap() {
+				keyTypeName := g.tsElemType(field.Message.Fields[0])
+				if keyTypeName != "number" {
+					keyTypeName = "string"
+				}
+				buf.WriteString("{[key: ")
+				buf.WriteString(keyTypeName)
+				buf.WriteString("]: ")
+				buf.WriteString(g.tsElemType(field.Message.Fields[1]))
+				buf.WriteString("}")
+			} else if field.Desc.IsList() {
+				buf.WriteString("Array<")
+				buf.WriteString(tsTypeName)
+				buf.WriteString(">")
+			} else {
+				buf.WriteString(tsTypeName)
+			}
+			buf.WriteString(";\n")
+		}
+		buf.WriteString(tab)
+		buf.WriteString("}\n\n")
+
+	}
+
+	buf.WriteString("}\n")
+	response := new(pluginpb.CodeGeneratorResponse_File)
+	generatedFileName := "proto.model.ts"
+	fileContent := buf.String()
+	response.Name = &generatedFileName
+	response.Content = &fileContent
+	return response
+}
+
+// tsElemType returns the ts type of a single value of the field, the element
+// type for repeated fields
+func (g *Generator) tsElemType(field *protogen.Field) string {
+	switch {
+	case field.Enum != nil:
+		return flatName(field.Enum.Desc)
+	case wellKnown(field) != nil:
+		return wellKnown(field).tsType
+	case field.Message != nil:
+		return flatName(field.Message.Desc)
+	default:
+		return g.getTsTypesMapping(field.Desc.Kind())
+	}
+}
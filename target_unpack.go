@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const targetUnpack = "unpack"
+
+func init() {
+	RegisterTarget(&fileTarget{name: targetUnpack, generate: (*Generator).generateUnpackFile})
+}
+
+// unpackIdentifiers are the exported identifiers written by the unpack target
+var unpackIdentifiers = []string{
+	"Message", "Register", "New", "RegisteredCmds", "UnknownCmdError", "DecodeError", "CmdTypeError",
+	"Unpack", "CmdMessage", "CmdOf", "UnpackAs",
+}
+
+func (g *Generator) generateUnpackFile(file *protogen.File) *pluginpb.CodeGeneratorResponse_File {
+	buf := new(bytes.Buffer)
+	imports := g.newGoImports(file)
+	g.checkGoIdents(file, targetUnpack, unpackIdentifiers)
+
+	buf.WriteString("// Message is implemented by the command messages\n")
+	buf.WriteString("type Message interface {\n")
+	buf.WriteString("\t" + g.goMessageMethods(imports) + "\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("var (\n")
+	buf.WriteString("\tregistryMu " + imports.use("sync") + ".RWMutex\n")
+	buf.WriteString("\tregistry   = map[int32]func() Message{\n")
+	for _, msg := range g.allMessages(file) {
+		if !g.isCmdType(msg) {
+			continue
+		}
+		typeName := msg.GoIdent.GoName
+		buf.WriteString("\t\tCmd_" + typeName + ": func() Message { return new(" + typeName + ") },\n")
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString(")\n\n")
+
+	buf.WriteString("// Register sets the factory of the messages of the command, replacing the\n")
+	buf.WriteString("// generated one if any, a nil factory removes the command. It is safe for\n")
+	buf.WriteString("// concurrent use with New and Unpack.\n")
+	buf.WriteString("func Register(cmd int32, factory func() Message) {\n")
+	buf.WriteString("\tregistryMu.Lock()\n")
+	buf.WriteString("\tdefer registryMu.Unlock()\n")
+	buf.WriteString("\tif factory == nil {\n")
+	buf.WriteString("\t\tdelete(registry, cmd)\n")
+	buf.WriteString("\t\treturn\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tregistry[cmd] = factory\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// New returns an empty message of the command, nil if the command is unknown\n")
+	buf.WriteString("func New(cmd int32) Message {\n")
+	buf.WriteString("\tregistryMu.RLock()\n")
+	buf.WriteString("\tfactory, ok := registry[cmd]\n")
+	buf.WriteString("\tregistryMu.RUnlock()\n")
+	buf.WriteString("\tif !ok {\n")
+	buf.WriteString("\t\treturn nil\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn factory()\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// RegisteredCmds returns the commands having a factory, sorted by id\n")
+	buf.WriteString("func RegisteredCmds() []int32 {\n")
+	buf.WriteString("\tregistryMu.RLock()\n")
+	buf.WriteString("\tdefer registryMu.RUnlock()\n")
+	buf.WriteString("\tcmds := make([]int32, 0, len(registry))\n")
+	buf.WriteString("\tfor cmd := range registry {\n")
+	buf.WriteString("\t\tcmds = append(cmds, cmd)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\t" + imports.use("sort") + ".Slice(cmds, func(i, j int) bool { return cmds[i] < cmds[j] })\n")
+	buf.WriteString("\treturn cmds\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// UnknownCmdError is returned by Unpack for a command without message\n")
+	buf.WriteString("type UnknownCmdError struct {\n")
+	buf.WriteString("\tCmd int32\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("func (e *UnknownCmdError) Error() string {\n")
+	buf.WriteString("\treturn " + imports.use("fmt") + ".Sprintf(\"unknown cmd 0x%X\", e.Cmd)\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// DecodeError is returned by Unpack when the data is not a valid message of the\n")
+	buf.WriteString("// command\n")
+	buf.WriteString("type DecodeError struct {\n")
+	buf.WriteString("\tCmd  int32\n")
+	buf.WriteString("\tName string // name of the command, e.g. LoginRequest\n")
+	buf.WriteString("\tErr  error\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("func (e *DecodeError) Error() string {\n")
+	buf.WriteString("\treturn " + imports.use("fmt") + ".Sprintf(\"decoding cmd 0x%X %s: %v\", e.Cmd, e.Name, e.Err)\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("func (e *DecodeError) Unwrap() error {\n")
+	buf.WriteString("\treturn e.Err\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// CmdTypeError is returned by UnpackAs when the command carries another message\n")
+	buf.WriteString("// than the one asked for\n")
+	buf.WriteString("type CmdTypeError struct {\n")
+	buf.WriteString("\tCmd  int32\n")
+	buf.WriteString("\tGot  string // go type of the message of the command, e.g. *game.LoginRequest\n")
+	buf.WriteString("\tWant string // go type asked for\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("func (e *CmdTypeError) Error() string {\n")
+	buf.WriteString("\treturn " + imports.use("fmt") + ".Sprintf(\"cmd 0x%X carries %s, not %s\", e.Cmd, e.Got, e.Want)\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// Unpack decodes the data into a new message of the command, the error is an\n")
+	buf.WriteString("// *UnknownCmdError or a *DecodeError\n")
+	buf.WriteString("func Unpack(fromCmd int32, data []byte) (Message, error) {\n")
+	buf.WriteString("\tpb := New(fromCmd)\n")
+	buf.WriteString("\tif pb == nil {\n")
+	buf.WriteString("\t\treturn nil, &UnknownCmdError{Cmd: fromCmd}\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif err := " + g.goUnmarshal("pb", "data", imports) + "; err != nil {\n")
+	buf.WriteString("\t\treturn nil, &DecodeError{Cmd: fromCmd, Name: Cmd(fromCmd).String(), Err: err}\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn pb, nil\n")
+	buf.WriteString("}\n")
+
+	g.generateUnpackAs(buf, file, imports)
+
+	content := new(bytes.Buffer)
+	g.generateGoFileHeader(content, file)
+	imports.write(content)
+	content.Write(buf.Bytes())
+
+	response := new(pluginpb.CodeGeneratorResponse_File)
+	generatedFileName := g.filename(file) + ".unpack.go"
+	fileContent := content.String()
+	response.Name = &generatedFileName
+	response.Content = &fileContent
+	return response
+}
+
+// generateUnpackAs writes the generic helpers, constrained to the command
+// messages of the file so that a wrong type is a compile error
+func (g *Generator) generateUnpackAs(buf *bytes.Buffer, file *protogen.File, imports *goImports) {
+	var typeNames []string
+	for _, msg := range g.allMessages(file) {
+		if g.isCmdType(msg) {
+			typeNames = append(typeNames, msg.GoIdent.GoName)
+		}
+	}
+	if len(typeNames) == 0 {
+		return
+	}
+
+	buf.WriteString("\n// CmdMessage is the set of the command messages\n")
+	buf.WriteString("type CmdMessage interface {\n")
+	buf.WriteString("\t*" + strings.Join(typeNames, " | *") + "\n")
+	buf.WriteString("\tMessage\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// CmdOf returns the command of the message type T\n")
+	buf.WriteString("func CmdOf[T CmdMessage]() int32 {\n")
+	buf.WriteString("\tvar msg T\n")
+	buf.WriteString("\tswitch any(msg).(type) {\n")
+	for _, typeName := range typeNames {
+		buf.WriteString("\tcase *" + typeName + ":\n")
+		buf.WriteString("\t\treturn Cmd_" + typeName + "\n")
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tpanic(\"unreachable\")\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// UnpackAs decodes the data of the command into a message of type T, the error\n")
+	buf.WriteString("// is a *CmdTypeError when the command does not carry a T, else as for Unpack\n")
+	buf.WriteString("func UnpackAs[T CmdMessage](cmd int32, data []byte) (T, error) {\n")
+	buf.WriteString("\tvar zero T\n")
+	buf.WriteString("\tpb, err := Unpack(cmd, data)\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn zero, err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tmsg, ok := pb.(T)\n")
+	buf.WriteString("\tif !ok {\n")
+	fmtPkg := imports.use("fmt")
+	buf.WriteString("\t\treturn zero, &CmdTypeError{Cmd: cmd, Got: " + fmtPkg + ".Sprintf(\"%T\", pb), Want: " + fmtPkg + ".Sprintf(\"%T\", zero)}\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn msg, nil\n")
+	buf.WriteString("}\n")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// wellKnownType describes how the targets render a google.protobuf type
+type wellKnownType struct {
+	goType    string                // go type taken by the pack constructors, %s is the goImport package
+	goImport  protogen.GoImportPath // package of goType, if not builtin
+	goConvert string                // converts the argument (%[1]s) to the message of the package %[2]s
+	goNew     string                // same as goConvert with the constructors of the golang packages, if any
+	tsType    string
+	javaType  string
+}
+
+// wrapperConvert converts a pointer argument to a wrapper message, nil stays
+// nil. The message is built as a composite literal, the helper functions of
+// the golang well-known packages are missing from the gogo types package.
+func wrapperConvert(wrapper string) string {
+	return "func() *%[2]s." + wrapper + " {\n" +
+		"\t\t\tif %[1]s == nil {\n" +
+		"\t\t\t\treturn nil\n" +
+		"\t\t\t}\n" +
+		"\t\t\treturn &%[2]s." + wrapper + "{Value: *%[1]s}\n" +
+		"\t\t}()"
+}
+
+// wellKnownTypes maps the full name of the well-known types to their
+// rendering, the types missing here are passed through as proto messages
+var wellKnownTypes = map[protoreflect.FullName]*wellKnownType{
+	"google.protobuf.Timestamp": {
+		goType: "%s.Time", goImport: "time", tsType: "Date", javaType: "java.time.Instant",
+		goConvert: "&%[2]s.Timestamp{Seconds: %[1]s.Unix(), Nanos: int32(%[1]s.Nanosecond())}",
+		goNew:     "%[2]s.New(%[1]s)",
+	},
+	"google.protobuf.Duration": {
+		goType: "%s.Duration", goImport: "time", tsType: "number", javaType: "java.time.Duration",
+		goConvert: "&%[2]s.Duration{Seconds: %[1]s.Nanoseconds() / 1e9, Nanos: int32(%[1]s.Nanoseconds() %% 1e9)}",
+		goNew:     "%[2]s.New(%[1]s)",
+	},
+	"google.protobuf.DoubleValue": {
+		goType: "*float64", goConvert: wrapperConvert("DoubleValue"),
+		tsType: "number | null", javaType: "Double",
+	},
+	"google.protobuf.FloatValue": {
+		goType: "*float32", goConvert: wrapperConvert("FloatValue"),
+		tsType: "number | null", javaType: "Float",
+	},
+	"google.protobuf.Int64Value": {
+		goType: "*int64", goConvert: wrapperConvert("Int64Value"),
+		tsType: "number | null", javaType: "Long",
+	},
+	"google.protobuf.UInt64Value": {
+		goType: "*uint64", goConvert: wrapperConvert("UInt64Value"),
+		tsType: "number | null", javaType: "Long",
+	},
+	"google.protobuf.Int32Value": {
+		goType: "*int32", goConvert: wrapperConvert("Int32Value"),
+		tsType: "number | null", javaType: "Integer",
+	},
+	"google.protobuf.UInt32Value": {
+		goType: "*uint32", goConvert: wrapperConvert("UInt32Value"),
+		tsType: "number | null", javaType: "Integer",
+	},
+	"google.protobuf.BoolValue": {
+		goType: "*bool", goConvert: wrapperConvert("BoolValue"),
+		tsType: "boolean | null", javaType: "Boolean",
+	},
+	"google.protobuf.StringValue": {
+		goType: "*string", goConvert: wrapperConvert("StringValue"),
+		tsType: "string | null", javaType: "String",
+	},
+	"google.protobuf.BytesValue": {
+		goType: "*[]byte", goConvert: wrapperConvert("BytesValue"),
+		tsType: "Uint8Array | null", javaType: "com.google.protobuf.ByteString",
+	},
+	"google.protobuf.Any": {
+		tsType: "any", javaType: "com.google.protobuf.Any",
+	},
+	"google.protobuf.Struct": {
+		tsType: "{[key: string]: any}", javaType: "com.google.protobuf.Struct",
+	},
+	"google.protobuf.Value": {
+		tsType: "any", javaType: "com.google.protobuf.Value",
+	},
+	"google.protobuf.Empty": {
+		tsType: "{}", javaType: "com.google.protobuf.Empty",
+	},
+}
+
+// wellKnown returns the rendering of the field type if it is a well-known type
+func wellKnown(field *protogen.Field) *wellKnownType {
+	if field.Message == nil {
+		return nil
+	}
+	return wellKnownTypes[field.Message.Desc.FullName()]
+}
+
+// goWellKnownArgument returns the type of the constructor argument and the
+// expression converting it to the field value. Only singular fields are
+// converted, repeated and map fields take the proto messages.
+func (g *Generator) goWellKnownArgument(field *protogen.Field, argumentName string, imports *goImports) (string, string, bool) {
+	wkt := wellKnown(field)
+	if wkt == nil || wkt.goType == "" || field.Desc.IsList() || field.Desc.IsMap() {
+		return "", "", false
+	}
+	goType := wkt.goType
+	if wkt.goImport != "" {
+		goType = fmt.Sprintf(goType, imports.use(wkt.goImport))
+	}
+	pkg := imports.use(field.Message.GoIdent.GoImportPath)
+	return goType, g.goWellKnown(wkt, argumentName, pkg), true
+}